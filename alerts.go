@@ -0,0 +1,143 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// AlertSeverity represents the severity of an alert raised by a line of output matching an AlertRule.
+type AlertSeverity string
+
+const (
+	// AlertSeverityWarning indicates a line of output that may point to a problem.
+	AlertSeverityWarning AlertSeverity = "warning"
+
+	// AlertSeverityError indicates a line of output that points to a problem, even if the component keeps running.
+	AlertSeverityError AlertSeverity = "error"
+)
+
+// ParseAlertSeverity parses the provided string value into an AlertSeverity.
+// It returns the parsed AlertSeverity or an error if the value is not a valid alert severity.
+func ParseAlertSeverity(value string) (AlertSeverity, error) {
+	switch AlertSeverity(value) {
+	case AlertSeverityWarning:
+		return AlertSeverityWarning, nil
+	case AlertSeverityError:
+		return AlertSeverityError, nil
+	}
+	return "", ErrInvalidAlertSeverity{v: value}
+}
+
+// rank returns the order of severities, where a higher rank is more severe.
+func (s AlertSeverity) rank() int {
+	switch s {
+	case AlertSeverityWarning:
+		return 1
+	case AlertSeverityError:
+		return 2
+	}
+	return 0
+}
+
+// AlertRule raises an alert for every line of output of a component that matches it, see WithAlertRules.
+//
+// Fields:
+// - Pattern: The regular expression lines of output are matched against.
+// - Severity: The severity of the alert raised by matching lines.
+type AlertRule struct {
+	Pattern  *regexp.Regexp
+	Severity AlertSeverity
+}
+
+// ComponentAlerts summarizes the alerts raised by the output of a component since it was last started.
+//
+// Fields:
+// - Severity: The highest severity of the alerts raised.
+// - Count: The number of lines of output that raised an alert.
+type ComponentAlerts struct {
+	Severity AlertSeverity `json:"severity"`
+	Count    int           `json:"count"`
+}
+
+// componentAlerts evaluates the alert rules of each component and tracks the alerts raised.
+// It is safe for concurrent use.
+type componentAlerts struct {
+	lock   sync.Mutex
+	rules  map[string][]AlertRule
+	alerts map[string]ComponentAlerts
+}
+
+// newComponentAlerts creates a new componentAlerts without any rules.
+func newComponentAlerts() *componentAlerts {
+	return &componentAlerts{rules: make(map[string][]AlertRule), alerts: make(map[string]ComponentAlerts)}
+}
+
+// validate checks that all rules have a pattern and a valid severity.
+func (a *componentAlerts) validate() error {
+	for id, rules := range a.rules {
+		for _, rule := range rules {
+			if rule.Pattern == nil {
+				return fmt.Errorf("alert rule of %s has no pattern", id)
+			}
+			_, err := ParseAlertSeverity(string(rule.Severity))
+			if err != nil {
+				return fmt.Errorf("invalid alert rule of %s: %w", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// evaluate matches the text of record against the rules of its component. If any rule matches, it sets the alert
+// of record to the highest severity of the matching rules, and counts the alert.
+func (a *componentAlerts) evaluate(record *OutputRecord) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var severity AlertSeverity
+	for _, rule := range a.rules[record.ComponentID] {
+		if rule.Severity.rank() > severity.rank() && rule.Pattern.MatchString(record.Text) {
+			severity = rule.Severity
+		}
+	}
+	if severity == "" {
+		return
+	}
+
+	record.Alert = severity
+	alerts := a.alerts[record.ComponentID]
+	alerts.Count++
+	if severity.rank() > alerts.Severity.rank() {
+		alerts.Severity = severity
+	}
+	a.alerts[record.ComponentID] = alerts
+}
+
+// clear removes the alerts raised by the output of a component.
+func (a *componentAlerts) clear(id string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.alerts, id)
+}
+
+// get returns the alerts raised by the output of a component, if any.
+func (a *componentAlerts) get(id string) (ComponentAlerts, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	alerts, ok := a.alerts[id]
+	return alerts, ok
+}
+
+// ErrInvalidAlertSeverity is an error type representing an invalid alert severity.
+type ErrInvalidAlertSeverity struct {
+	v string
+}
+
+func (e ErrInvalidAlertSeverity) Error() string {
+	return fmt.Sprintf("invalid alert severity %s", e.v)
+}
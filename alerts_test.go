@@ -0,0 +1,90 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestAlertRules(t *testing.T) {
+	api := &mockComponent{}
+	db := &mockComponent{}
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("api", api).AddComponent("db", db),
+		WithAlertRules(
+			"api",
+			AlertRule{Pattern: regexp.MustCompile(`(?i)deprecated`), Severity: AlertSeverityWarning},
+			AlertRule{Pattern: regexp.MustCompile(`FATAL|panic:`), Severity: AlertSeverityError},
+		),
+	)
+	assert.NoError(t, err)
+
+	api.w.WriteString("listening")
+	api.w.WriteString("config key is deprecated")
+	db.w.WriteString("FATAL: ignored since db has no rules")
+	status, err := env.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &ComponentAlerts{Severity: AlertSeverityWarning, Count: 1}, status.Components[0][0].Alerts)
+	assert.Nil(t, status.Components[0][1].Alerts)
+
+	api.w.WriteString("FATAL: deprecated connection lost")
+	api.w.WriteString("still deprecated")
+	status, err = env.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &ComponentAlerts{Severity: AlertSeverityError, Count: 3}, status.Components[0][0].Alerts)
+
+	matches := env.SearchOutput(0, Alerting())
+	assert.Len(t, matches, 3)
+	assert.Equal(t, AlertSeverityWarning, matches[0].Record.Alert)
+	assert.Equal(t, AlertSeverityError, matches[1].Record.Alert)
+	assert.Equal(t, "FATAL: deprecated connection lost", matches[1].Record.Text)
+	assert.Contains(
+		t,
+		string(matches[1].Record.legacy(OutputRenderingANSI)),
+		"<msg>\u001B[1;31mFATAL: deprecated connection lost\u001B[22;39m",
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/output/search?alerts=true&component=api&contains=FATAL", nil)
+	res := httptest.NewRecorder()
+	getOutputSearchHandler{env: env}.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	var response GetOutputSearchResponse
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &response))
+	assert.Len(t, response.Matches, 1)
+	assert.Equal(t, AlertSeverityError, response.Matches[0].Record.Alert)
+
+	req = httptest.NewRequest(http.MethodGet, "/output/search?alerts=maybe", nil)
+	res = httptest.NewRecorder()
+	getOutputSearchHandler{env: env}.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	// alerts are cleared once the component is started again
+	_, err = env.StartComponent(context.Background(), "api")
+	assert.NoError(t, err)
+	status, err = env.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, status.Components[0][0].Alerts)
+
+	_, err = NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("api", &mockComponent{}),
+		WithAlertRules("api", AlertRule{Pattern: regexp.MustCompile("x"), Severity: "critical"}),
+	)
+	assert.ErrorIs(t, err, ErrInvalidAlertSeverity{v: "critical"})
+
+	_, err = NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("api", &mockComponent{}),
+		WithAlertRules("missing", AlertRule{Pattern: regexp.MustCompile("x"), Severity: AlertSeverityError}),
+	)
+	assert.ErrorIs(t, err, ErrInvalidComponentID{id: "missing", msg: "alert rules set for a component that does not exist"})
+}
@@ -0,0 +1,279 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// OutputRendering represents how the text of output records is rendered for a consumer.
+type OutputRendering string
+
+const (
+	// OutputRenderingPlain renders text without any color.
+	OutputRenderingPlain OutputRendering = "plain"
+
+	// OutputRenderingANSI renders colored text using ANSI escape codes, for terminals.
+	OutputRenderingANSI OutputRendering = "ansi"
+
+	// OutputRenderingHTML renders text as HTML, where colored text is wrapped with styled span elements.
+	OutputRenderingHTML OutputRendering = "html"
+)
+
+// ParseOutputRendering parses the provided string value into an OutputRendering.
+// It returns the parsed OutputRendering or an error if the value is not a valid output rendering.
+func ParseOutputRendering(value string) (OutputRendering, error) {
+	switch OutputRendering(value) {
+	case OutputRenderingPlain:
+		return OutputRenderingPlain, nil
+	case OutputRenderingANSI:
+		return OutputRenderingANSI, nil
+	case OutputRenderingHTML:
+		return OutputRenderingHTML, nil
+	}
+	return "", ErrInvalidOutputRendering{v: value}
+}
+
+// RenderANSI renders text that may contain ANSI escape codes for a consumer, e.g. converting colors to HTML,
+// or stripping them for plain text.
+func RenderANSI(text string, rendering OutputRendering) string {
+	plain, spans := parseANSI(text)
+	return renderText(plain, spans, rendering)
+}
+
+// OutputSpan describes the style of a part of the text of an OutputRecord.
+//
+// Fields:
+// - Start: The byte offset in the text the span starts at.
+// - End: The byte offset in the text the span ends at, exclusive.
+// - Color: The name of the foreground color of the span, such as red or bright_blue. Empty for the default color.
+// - Bold: Whether the span is bold.
+type OutputSpan struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Color string `json:"color,omitempty"`
+	Bold  bool   `json:"bold,omitempty"`
+}
+
+// ansiColors maps the ANSI SGR foreground color codes to color names.
+var ansiColors = map[int]string{
+	30: "black",
+	31: "red",
+	32: "green",
+	33: "yellow",
+	34: "blue",
+	35: "magenta",
+	36: "cyan",
+	37: "white",
+	90: "bright_black",
+	91: "bright_red",
+	92: "bright_green",
+	93: "bright_yellow",
+	94: "bright_blue",
+	95: "bright_magenta",
+	96: "bright_cyan",
+	97: "bright_white",
+}
+
+// htmlColors maps color names to the CSS colors used when rendering HTML.
+var htmlColors = map[string]string{
+	"black":          "#000000",
+	"red":            "#cd3131",
+	"green":          "#0dbc79",
+	"yellow":         "#e5e510",
+	"blue":           "#2472c8",
+	"magenta":        "#bc3fbc",
+	"cyan":           "#11a8cd",
+	"white":          "#e5e5e5",
+	"bright_black":   "#666666",
+	"bright_red":     "#f14c4c",
+	"bright_green":   "#23d18b",
+	"bright_yellow":  "#f5f543",
+	"bright_blue":    "#3b8eea",
+	"bright_magenta": "#d670d6",
+	"bright_cyan":    "#29b8db",
+	"bright_white":   "#ffffff",
+}
+
+// parseANSI strips all ANSI escape sequences from s, returning the plain text along with spans describing
+// the colors and bold style set by SGR sequences. Any other escape sequence is discarded.
+// It returns nil spans if no part of the text is styled.
+func parseANSI(s string) (string, []OutputSpan) {
+	if !strings.Contains(s, "\u001B") {
+		return s, nil
+	}
+
+	var text strings.Builder
+	var spans []OutputSpan
+	current := OutputSpan{}
+	flush := func() {
+		current.End = text.Len()
+		if current.End > current.Start && (current.Color != "" || current.Bold) {
+			spans = append(spans, current)
+		}
+		current.Start = current.End
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != '\u001B' {
+			text.WriteByte(s[i])
+			i++
+			continue
+		}
+		if i+1 >= len(s) {
+			break
+		}
+
+		switch s[i+1] {
+		case '[':
+			// control sequence: parameters and intermediate bytes followed by a final byte
+			end := i + 2
+			for end < len(s) && (s[end] < 0x40 || s[end] > 0x7E) {
+				end++
+			}
+			if end >= len(s) {
+				i = len(s)
+				continue
+			}
+			if s[end] == 'm' {
+				flush()
+				applySGR(&current, s[i+2:end])
+			}
+			i = end + 1
+		case ']':
+			// operating system command: terminated by BEL or ST
+			end := i + 2
+			for end < len(s) && s[end] != '\u0007' && !(s[end] == '\u001B' && end+1 < len(s) && s[end+1] == '\\') {
+				end++
+			}
+			if end < len(s) && s[end] == '\u001B' {
+				end++
+			}
+			i = end + 1
+		default:
+			// other escape sequences: intermediate bytes followed by a final byte
+			end := i + 1
+			for end < len(s) && s[end] >= 0x20 && s[end] <= 0x2F {
+				end++
+			}
+			i = end + 1
+		}
+	}
+	flush()
+
+	return text.String(), spans
+}
+
+// applySGR updates span with the style set by the parameters of an SGR sequence.
+func applySGR(span *OutputSpan, params string) {
+	if params == "" {
+		params = "0"
+	}
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil {
+			continue
+		}
+		switch {
+		case code == 0:
+			span.Color = ""
+			span.Bold = false
+		case code == 1:
+			span.Bold = true
+		case code == 22:
+			span.Bold = false
+		case code == 39:
+			span.Color = ""
+		case code == 38 || code == 48:
+			// extended colors are not supported, so their arguments are skipped
+			if i+1 < len(codes) && codes[i+1] == "5" {
+				i += 2
+			} else if i+1 < len(codes) && codes[i+1] == "2" {
+				i += 4
+			}
+		default:
+			if color, ok := ansiColors[code]; ok {
+				span.Color = color
+			}
+		}
+	}
+}
+
+// renderText renders text styled by spans.
+func renderText(text string, spans []OutputSpan, rendering OutputRendering) string {
+	if rendering == OutputRenderingPlain || (len(spans) == 0 && rendering != OutputRenderingHTML) {
+		return text
+	}
+
+	var result strings.Builder
+	position := 0
+	write := func(s string) {
+		if rendering == OutputRenderingHTML {
+			s = html.EscapeString(s)
+		}
+		result.WriteString(s)
+	}
+	for _, span := range spans {
+		if span.Start < position || span.End > len(text) || span.Start >= span.End {
+			continue
+		}
+		write(text[position:span.Start])
+		if rendering == OutputRenderingHTML {
+			result.WriteString(htmlStyle(span))
+			write(text[span.Start:span.End])
+			result.WriteString("</span>")
+		} else {
+			start, end := ansiStyle(span)
+			result.WriteString(start)
+			write(text[span.Start:span.End])
+			result.WriteString(end)
+		}
+		position = span.End
+	}
+	write(text[position:])
+	return result.String()
+}
+
+// ansiStyle returns the SGR sequences setting and resetting the style of span.
+func ansiStyle(span OutputSpan) (string, string) {
+	var set, reset []string
+	if span.Bold {
+		set = append(set, "1")
+		reset = append(reset, "22")
+	}
+	for code, color := range ansiColors {
+		if color == span.Color {
+			set = append(set, strconv.Itoa(code))
+			reset = append(reset, "39")
+			break
+		}
+	}
+	return fmt.Sprintf("\u001B[%sm", strings.Join(set, ";")), fmt.Sprintf("\u001B[%sm", strings.Join(reset, ";"))
+}
+
+// htmlStyle returns the opening span element setting the style of span.
+func htmlStyle(span OutputSpan) string {
+	var styles []string
+	if color, ok := htmlColors[span.Color]; ok {
+		styles = append(styles, "color: "+color)
+	}
+	if span.Bold {
+		styles = append(styles, "font-weight: bold")
+	}
+	return fmt.Sprintf(`<span style="%s">`, strings.Join(styles, "; "))
+}
+
+// ErrInvalidOutputRendering is an error type representing an invalid output rendering.
+type ErrInvalidOutputRendering struct {
+	v string
+}
+
+func (e ErrInvalidOutputRendering) Error() string {
+	return fmt.Sprintf("invalid output rendering %s", e.v)
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseANSI(t *testing.T) {
+	color := AnsiColor{}
+	text, spans := parseANSI("plain " + color.Red("red") + " and " + color.Cyan("cyan"))
+	assert.Equal(t, "plain red and cyan", text)
+	assert.Equal(t, []OutputSpan{
+		{Start: 6, End: 9, Color: "red"},
+		{Start: 14, End: 18, Color: "cyan"},
+	}, spans)
+
+	text, spans = parseANSI("\u001B[1;92mbold\u001B[22m green\u001B[0m")
+	assert.Equal(t, "bold green", text)
+	assert.Equal(t, []OutputSpan{
+		{Start: 0, End: 4, Color: "bright_green", Bold: true},
+		{Start: 4, End: 10, Color: "bright_green"},
+	}, spans)
+
+	// unsupported styles and other escape sequences are discarded
+	text, spans = parseANSI("\u001B[38;5;208mextended\u001B[m \u001B[2Kcleared \u001B]0;title\u0007titled\u001B(B")
+	assert.Equal(t, "extended cleared titled", text)
+	assert.Nil(t, spans)
+
+	text, spans = parseANSI("no escape codes")
+	assert.Equal(t, "no escape codes", text)
+	assert.Nil(t, spans)
+}
+
+func TestRenderANSI(t *testing.T) {
+	color := AnsiColor{}
+	text := "<b>" + color.Red("red") + " & " + "\u001B[1mbold\u001B[22m"
+
+	assert.Equal(t, "<b>red & bold", RenderANSI(text, OutputRenderingPlain))
+	assert.Equal(t, "<b>\u001B[31mred\u001B[39m & \u001B[1mbold\u001B[22m", RenderANSI(text, OutputRenderingANSI))
+	assert.Equal(
+		t,
+		`&lt;b&gt;<span style="color: #cd3131">red</span> &amp; <span style="font-weight: bold">bold</span>`,
+		RenderANSI(text, OutputRenderingHTML),
+	)
+
+	_, err := ParseOutputRendering("markdown")
+	assert.ErrorIs(t, err, ErrInvalidOutputRendering{v: "markdown"})
+}
+
+func TestOutputRendering(t *testing.T) {
+	component := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+	component.w.WriteStringWithTime(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), "status "+component.w.Color.Green("ok"))
+
+	// records keep colors as spans rather than escape codes
+	reader := env.Output()
+	record := <-reader.Records()
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, "status ok", record.Text)
+	assert.Equal(t, []OutputSpan{{Start: 7, End: 9, Color: "green"}}, record.Spans)
+	assert.Equal(t, "status \u001B[32mok\u001B[39m", record.Render(OutputRenderingANSI))
+
+	stream := func(target string) string {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+		res := httptest.NewRecorder()
+		getOutputHandler{env: env}.ServeHTTP(res, req)
+		return res.Body.String()
+	}
+	record1 := `{"component_id":"component","time":"2000-01-01T00:00:00Z","stream":"stdout","level":"info",`
+
+	assert.Equal(t, record1+`"text":"status ok","spans":[{"start":7,"end":9,"color":"green"}]}`+"\n", stream("/output?format=ndjson"))
+	assert.Equal(t, record1+`"text":"status \u001b[32mok\u001b[39m"}`+"\n", stream("/output?format=ndjson&color=ansi"))
+	var html OutputRecord
+	assert.NoError(t, json.Unmarshal([]byte(stream("/output?format=ndjson&color=html")), &html))
+	assert.Equal(t, `status <span style="color: #0dbc79">ok</span>`, html.Text)
+	assert.Nil(t, html.Spans)
+	assert.Contains(t, stream("/output"), "<msg>status \u001B[32mok\u001B[39m\n")
+	assert.Contains(t, stream("/output?color=plain"), "<msg>status ok\n")
+	assert.Contains(t, stream("/output?color=none"), "invalid color parameter none")
+
+	req := httptest.NewRequest(http.MethodGet, "/output/search?contains=status&color=html", nil)
+	res := httptest.NewRecorder()
+	getOutputSearchHandler{env: env}.ServeHTTP(res, req)
+	var response GetOutputSearchResponse
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &response))
+	assert.Len(t, response.Matches, 1)
+	assert.Equal(t, `status <span style="color: #0dbc79">ok</span>`, response.Matches[0].Record.Text)
+}
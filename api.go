@@ -14,6 +14,8 @@ import (
 	"github.com/perimeterx/envite/ui"
 	"io"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -21,28 +23,261 @@ import (
 const (
 	contentType                    = "Content-Type"
 	applicationJSON                = "application/json"
+	textEventStream                = "text/event-stream"
+	cacheControl                   = "Cache-Control"
+	noCache                        = "no-cache"
 	accessControl                  = "Access-Control-Allow-Origin"
-	accessControlValue             = "*"
+	accessControlAny               = "*"
 	accessControlAllowHeaders      = "Access-Control-Allow-Headers"
-	accessControlAllowHeadersValue = "Content-Type, Origin, Accept, token"
+	accessControlAllowHeadersValue = "Content-Type, Origin, Accept, Authorization, token"
 	accessControlAllowMethods      = "Access-Control-Allow-Methods"
 	accessControlAllowMethodsValue = "GET,POST,PUT,DELETE,OPTIONS"
 	invalidContentType             = "invalid content type"
 	failedToReadBody               = "failed to read body"
+	waitParam                      = "wait"
+	asyncParam                     = "async"
+	linesParam                     = "lines"
+	sinceParam                     = "since"
+	disconnectSlowParam            = "disconnect_slow"
+	formatParam                    = "format"
+	untilParam                     = "until"
+	componentParam                 = "component"
+	streamParam                    = "stream"
+	containsParam                  = "contains"
+	regexParam                     = "regex"
+	contextParam                   = "context"
+	colorParam                     = "color"
+	alertsParam                    = "alerts"
+	applicationZip                 = "application/zip"
+	contentDisposition             = "Content-Disposition"
+	accept                         = "Accept"
+	applicationNDJSON              = "application/x-ndjson"
+	outputFormatLegacy             = "legacy"
+	outputFormatNDJSON             = "ndjson"
+	outputFormatSSE                = "sse"
+	outputEvent                    = "output"
 )
 
 // registerRoutes sets up the API endpoints using the provided router and environment.
 // It defines routes for api to manage all components, and a fallback route to serve the UI.
-func registerRoutes(router *mux.Router, env *Environment) {
-	apiRoute(router, http.MethodGet, "/status", getStatusHandler{env: env})
-	apiRoute(router, http.MethodPost, "/start_component", postStartHandler{env: env})
-	apiRoute(router, http.MethodPost, "/stop_component", postStopHandler{env: env})
-	apiRoute(router, http.MethodPost, "/apply", postApplyHandler{env: env})
-	apiRoute(router, http.MethodPost, "/stop_all", postStopAllHandler{env: env})
-	apiRoute(router, http.MethodGet, "/output", getOutputHandler{env: env})
+// Asynchronous jobs are canceled once ctx is canceled.
+func registerRoutes(ctx context.Context, router *mux.Router, env *Environment) {
+	for _, endpoint := range apiEndpoints(env, newJobManager(ctx)) {
+		apiRoute(router, endpoint.method, endpoint.path, endpoint.handler)
+	}
 	router.PathPrefix("/").Handler(newWebHandler())
 }
 
+// apiEndpoint describes an endpoint of the API, used both to route requests to its handler
+// and to document it in the OpenAPI document, see newOpenAPIDocument.
+//
+// Fields:
+// - id: The unique operation ID of the endpoint.
+// - method: The HTTP method of the endpoint.
+// - path: The path of the endpoint, where path parameters are wrapped with braces.
+// - summary: A short description of what the endpoint does.
+// - handler: The handler serving requests to the endpoint.
+// - params: The query and path parameters of the endpoint.
+// - body: A value of the type of the JSON request body, or nil if the endpoint expects no body.
+// - response: The content of a successful response, see apiJSON.
+// - lifecycle: Whether the endpoint runs a lifecycle operation, supporting the wait and async query parameters.
+type apiEndpoint struct {
+	id        string
+	method    string
+	path      string
+	summary   string
+	handler   http.Handler
+	params    []apiParam
+	body      any
+	response  []apiContent
+	lifecycle bool
+}
+
+// apiParam describes a query or path parameter of an apiEndpoint.
+type apiParam struct {
+	name        string
+	in          string
+	kind        string
+	description string
+}
+
+// apiContent describes a media type of a response of an apiEndpoint, where body is a value of the type of the response
+// body, or nil for text.
+type apiContent struct {
+	mediaType string
+	body      any
+}
+
+// apiJSON returns the content of a JSON response, where body is a value of its type, or nil for an empty object.
+func apiJSON(body any) []apiContent {
+	return []apiContent{{mediaType: applicationJSON, body: body}}
+}
+
+// apiReaderParams are the query parameters filtering and rendering output, see apiReaderOptions and apiOutputRendering.
+var apiReaderParams = []apiParam{
+	{name: linesParam, in: "query", kind: "integer", description: "Only the last lines kept in memory"},
+	{name: sinceParam, in: "query", kind: "string", description: "Only output written since an RFC 3339 timestamp"},
+	{name: untilParam, in: "query", kind: "string", description: "Only output written until an RFC 3339 timestamp"},
+	{name: componentParam, in: "query", kind: "string", description: "Only output of the given components"},
+	{name: streamParam, in: "query", kind: "string", description: "Only output written to the given streams"},
+	{name: containsParam, in: "query", kind: "string", description: "Only lines containing a substring"},
+	{name: regexParam, in: "query", kind: "string", description: "Only lines matching a regular expression"},
+	{name: alertsParam, in: "query", kind: "boolean", description: "Only lines that raised an alert"},
+	{name: colorParam, in: "query", kind: "string", description: "How colors are rendered, one of plain, ansi or html"},
+}
+
+// apiEndpoints returns all endpoints of the API, serving env and running asynchronous operations using jobs.
+func apiEndpoints(env *Environment, jobs *jobManager) []apiEndpoint {
+	jobParams := []apiParam{{name: "id", in: "path", kind: "string", description: "The ID of the job"}}
+	return []apiEndpoint{
+		{
+			id:       "getStatus",
+			method:   http.MethodGet,
+			path:     "/status",
+			summary:  "Get the status of the environment and all of its components",
+			handler:  getStatusHandler{env: env},
+			response: apiJSON(GetStatusResponse{}),
+		},
+		{
+			id:        "startComponent",
+			method:    http.MethodPost,
+			path:      "/start_component",
+			summary:   "Start a component",
+			handler:   postStartHandler{env: env, jobs: jobs},
+			body:      PostStartRequest{},
+			response:  apiJSON(PostStartResponse{}),
+			lifecycle: true,
+		},
+		{
+			id:        "stopComponent",
+			method:    http.MethodPost,
+			path:      "/stop_component",
+			summary:   "Stop a component",
+			handler:   postStopHandler{env: env, jobs: jobs},
+			body:      PostStopRequest{},
+			response:  apiJSON(PostStopResponse{}),
+			lifecycle: true,
+		},
+		{
+			id:        "restartComponent",
+			method:    http.MethodPost,
+			path:      "/restart_component",
+			summary:   "Restart a component, or start it if it is not running",
+			handler:   postRestartHandler{env: env, jobs: jobs},
+			body:      PostRestartRequest{},
+			response:  apiJSON(PostRestartResponse{}),
+			lifecycle: true,
+		},
+		{
+			id:        "apply",
+			method:    http.MethodPost,
+			path:      "/apply",
+			summary:   "Start the enabled components and stop all other components",
+			handler:   postApplyHandler{env: env, jobs: jobs},
+			body:      PostApplyRequest{},
+			response:  apiJSON(nil),
+			lifecycle: true,
+		},
+		{
+			id:       "exec",
+			method:   http.MethodPost,
+			path:     "/exec",
+			summary:  "Execute a command in a component",
+			handler:  postExecHandler{env: env},
+			body:     PostExecRequest{},
+			response: apiJSON(PostExecResponse{}),
+		},
+		{
+			id:       "plan",
+			method:   http.MethodPost,
+			path:     "/plan",
+			summary:  "Preview the actions applying the enabled components would take",
+			handler:  postPlanHandler{env: env},
+			body:     PostPlanRequest{},
+			response: apiJSON(Plan{}),
+		},
+		{
+			id:        "stopAll",
+			method:    http.MethodPost,
+			path:      "/stop_all",
+			summary:   "Stop all components, optionally cleaning them up",
+			handler:   postStopAllHandler{env: env, jobs: jobs},
+			body:      PostStopAllRequest{},
+			response:  apiJSON(nil),
+			lifecycle: true,
+		},
+		{
+			id:      "getOutput",
+			method:  http.MethodGet,
+			path:    "/output",
+			summary: "Stream the output of components",
+			handler: getOutputHandler{env: env},
+			params: append([]apiParam{
+				{name: formatParam, in: "query", kind: "string", description: "One of legacy, ndjson or sse"},
+				{name: disconnectSlowParam, in: "query", kind: "boolean", description: "Disconnect slow clients"},
+			}, apiReaderParams...),
+			response: []apiContent{
+				{mediaType: applicationNDJSON, body: OutputRecord{}},
+				{mediaType: textEventStream},
+				{mediaType: "text/plain"},
+			},
+		},
+		{
+			id:      "searchOutput",
+			method:  http.MethodGet,
+			path:    "/output/search",
+			summary: "Search the output kept in memory",
+			handler: getOutputSearchHandler{env: env},
+			params: append([]apiParam{
+				{name: contextParam, in: "query", kind: "integer", description: "Lines to include around each match"},
+			}, apiReaderParams...),
+			response: apiJSON(GetOutputSearchResponse{}),
+		},
+		{
+			id:       "downloadOutput",
+			method:   http.MethodGet,
+			path:     "/output/download",
+			summary:  "Download the log files of the environment as a zip archive",
+			handler:  getOutputDownloadHandler{env: env},
+			response: []apiContent{{mediaType: applicationZip}},
+		},
+		{
+			id:       "getEvents",
+			method:   http.MethodGet,
+			path:     "/events",
+			summary:  "Stream lifecycle events as server-sent events",
+			handler:  getEventsHandler{env: env},
+			response: []apiContent{{mediaType: textEventStream}},
+		},
+		{
+			id:       "getJob",
+			method:   http.MethodGet,
+			path:     "/jobs/{id}",
+			summary:  "Get the progress of an asynchronous operation",
+			handler:  getJobHandler{env: env, jobs: jobs},
+			params:   jobParams,
+			response: apiJSON(Job{}),
+		},
+		{
+			id:       "cancelJob",
+			method:   http.MethodDelete,
+			path:     "/jobs/{id}",
+			summary:  "Cancel an asynchronous operation",
+			handler:  deleteJobHandler{env: env, jobs: jobs},
+			params:   jobParams,
+			response: apiJSON(Job{}),
+		},
+		{
+			id:       "getOpenAPI",
+			method:   http.MethodGet,
+			path:     openAPIPath,
+			summary:  "Get the OpenAPI document describing the API",
+			handler:  getOpenAPIHandler{env: env},
+			response: apiJSON(nil),
+		},
+	}
+}
+
 // getStatusHandler handles requests to retrieve the current status of the environment or components within it.
 type getStatusHandler struct {
 	env *Environment
@@ -50,9 +285,11 @@ type getStatusHandler struct {
 
 // GetStatusResponse defines the structure of the response for a status request.
 // It includes details such as component ID, type, status, additional information, and environment variables.
+// Operation describes the lifecycle operation currently running on the environment, if any.
 type GetStatusResponse struct {
 	ID         string                         `json:"id"`
 	Components [][]GetStatusResponseComponent `json:"components"`
+	Operation  *RunningOperation              `json:"operation,omitempty"`
 }
 
 // GetStatusResponseComponent represents a single component's status within the environment.
@@ -64,11 +301,17 @@ type GetStatusResponse struct {
 // - Type: The type of the component, indicating its role or function within the environment.
 // - Status: The current status of the component, such as running, stopped, etc.
 // - Config: The component config.
+// - FailureReason: If the latest action on the component failed, the reason it failed, such as a start timeout.
+// - Error: If the latest action on the component failed, the error it failed with.
+// - Alerts: The alerts raised by the output of the component since it was last started, if any, see WithAlertRules.
 type GetStatusResponseComponent struct {
-	ID     string          `json:"id"`
-	Type   string          `json:"type"`
-	Status ComponentStatus `json:"status"`
-	Config map[string]any  `json:"config"`
+	ID            string                 `json:"id"`
+	Type          string                 `json:"type"`
+	Status        ComponentStatus        `json:"status"`
+	Config        map[string]any         `json:"config"`
+	FailureReason ComponentFailureReason `json:"failure_reason,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	Alerts        *ComponentAlerts       `json:"alerts,omitempty"`
 }
 
 // buildComponentInfo takes a Component and extracts its configuration object,
@@ -104,128 +347,341 @@ func (g getStatusHandler) ServeHTTP(writer http.ResponseWriter, request *http.Re
 
 // postApplyHandler handles requests to apply a given configuration to the environment.
 type postApplyHandler struct {
-	env *Environment
+	env  *Environment
+	jobs *jobManager
 }
 
-// postApplyRequest defines the expected request body for applying new configurations.
-type postApplyRequest struct {
+// PostApplyRequest defines the expected request body for applying new configurations.
+type PostApplyRequest struct {
 	EnabledComponentIDs []string `json:"enabled_component_ids"`
 }
 
 func (p postApplyHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	body := postApplyRequest{}
+	body := PostApplyRequest{}
 	if !apiParse(p.env, writer, request, &body) {
 		return
 	}
 
-	err := p.env.Apply(request.Context(), body.EnabledComponentIDs)
+	run := func(ctx context.Context, options []OperationOption) (any, error) {
+		return nil, p.env.Apply(ctx, body.EnabledComponentIDs, options...)
+	}
+	apiRun(p.env, p.jobs, writer, request, OperationApply, "", run)
+}
+
+// postPlanHandler handles requests to preview the actions applying a given configuration would take.
+type postPlanHandler struct {
+	env *Environment
+}
+
+// PostPlanRequest defines the expected request body for planning, identical to the one of applying.
+type PostPlanRequest struct {
+	EnabledComponentIDs []string `json:"enabled_component_ids"`
+}
+
+func (p postPlanHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	body := PostPlanRequest{}
+	if !apiParse(p.env, writer, request, &body) {
+		return
+	}
+
+	plan, err := p.env.Plan(request.Context(), body.EnabledComponentIDs)
 	if err != nil {
 		apiError(p.env, writer, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	apiSuccess(p.env, writer, nil, http.StatusOK)
+	apiSuccess(p.env, writer, plan, http.StatusOK)
 }
 
 // postStopAllHandler handles requests to stop all components in the environment, optionally cleaning up resources.
 type postStopAllHandler struct {
-	env *Environment
+	env  *Environment
+	jobs *jobManager
 }
 
-// postStopAllRequest defines the expected request body for stopping all components.
-type postStopAllRequest struct {
+// PostStopAllRequest defines the expected request body for stopping all components.
+type PostStopAllRequest struct {
 	Cleanup bool `json:"cleanup"`
 }
 
 func (p postStopAllHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	body := postStopAllRequest{}
+	body := PostStopAllRequest{}
 	if !apiParse(p.env, writer, request, &body) {
 		return
 	}
 
-	err := p.env.StopAll(request.Context())
-	if err != nil {
-		apiError(p.env, writer, err.Error(), http.StatusInternalServerError)
+	run := func(ctx context.Context, options []OperationOption) (any, error) {
+		err := p.env.StopAll(ctx, options...)
+		if err != nil {
+			return nil, err
+		}
+
+		if body.Cleanup {
+			err = p.env.Cleanup(ctx, options...)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	}
+	apiRun(p.env, p.jobs, writer, request, OperationStopAll, "", run)
+}
+
+// postStartHandler handles requests to start a specific component within the environment.
+type postStartHandler struct {
+	env  *Environment
+	jobs *jobManager
+}
+
+// PostStartRequest defines the expected request body for starting a component.
+// If WithDependencies is set, all components the component transitively depends on are started first.
+// If Cascade is set, all running components that transitively depend on the component are restarted once it started.
+type PostStartRequest struct {
+	ComponentID      string `json:"component_id"`
+	WithDependencies bool   `json:"with_dependencies"`
+	Cascade          bool   `json:"cascade"`
+}
+
+// PostStartResponse defines the response body for starting a component,
+// listing the IDs of all components that were started.
+type PostStartResponse struct {
+	Started []string `json:"started"`
+}
+
+func (p postStartHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	body := PostStartRequest{}
+	if !apiParse(p.env, writer, request, &body) {
 		return
 	}
 
-	if body.Cleanup {
-		err = p.env.Cleanup(request.Context())
+	run := func(ctx context.Context, options []OperationOption) (any, error) {
+		if body.WithDependencies {
+			options = append(options, WithDependencies())
+		}
+		if body.Cascade {
+			options = append(options, WithCascade())
+		}
+		started, err := p.env.StartComponent(ctx, body.ComponentID, options...)
 		if err != nil {
-			apiError(p.env, writer, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
+
+		return PostStartResponse{Started: started}, nil
 	}
+	apiRun(p.env, p.jobs, writer, request, OperationStartComponent, body.ComponentID, run)
+}
 
-	apiSuccess(p.env, writer, nil, http.StatusOK)
+// postStopHandler handles requests to stop a specific component within the environment.
+type postStopHandler struct {
+	env  *Environment
+	jobs *jobManager
 }
 
-// postStartHandler handles requests to start a specific component within the environment.
-type postStartHandler struct {
-	env *Environment
+// PostStopRequest defines the expected request body for stopping a component.
+// If WithDependents is set, all components that transitively depend on the component are stopped first.
+type PostStopRequest struct {
+	ComponentID    string `json:"component_id"`
+	WithDependents bool   `json:"with_dependents"`
 }
 
-// postStartRequest defines the expected request body for starting a component.
-type postStartRequest struct {
-	ComponentID string `json:"component_id"`
+// PostStopResponse defines the response body for stopping a component,
+// listing the IDs of all components that were stopped.
+type PostStopResponse struct {
+	Stopped []string `json:"stopped"`
 }
 
-func (p postStartHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	body := postStartRequest{}
+func (p postStopHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	body := PostStopRequest{}
 	if !apiParse(p.env, writer, request, &body) {
 		return
 	}
 
-	err := p.env.StartComponent(request.Context(), body.ComponentID)
-	if err != nil {
-		apiError(p.env, writer, err.Error(), http.StatusInternalServerError)
+	run := func(ctx context.Context, options []OperationOption) (any, error) {
+		if body.WithDependents {
+			options = append(options, WithDependents())
+		}
+		stopped, err := p.env.StopComponent(ctx, body.ComponentID, options...)
+		if err != nil {
+			return nil, err
+		}
+
+		return PostStopResponse{Stopped: stopped}, nil
+	}
+	apiRun(p.env, p.jobs, writer, request, OperationStopComponent, body.ComponentID, run)
+}
+
+// postRestartHandler handles requests to restart a specific component within the environment.
+type postRestartHandler struct {
+	env  *Environment
+	jobs *jobManager
+}
+
+// PostRestartRequest defines the expected request body for restarting a component.
+// If Cascade is set, all running components that transitively depend on the component are restarted afterward.
+type PostRestartRequest struct {
+	ComponentID string `json:"component_id"`
+	Cascade     bool   `json:"cascade"`
+}
+
+// PostRestartResponse defines the response body for restarting a component,
+// listing the IDs of all components that were restarted.
+type PostRestartResponse struct {
+	Restarted []string `json:"restarted"`
+}
+
+func (p postRestartHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	body := PostRestartRequest{}
+	if !apiParse(p.env, writer, request, &body) {
 		return
 	}
 
-	apiSuccess(p.env, writer, nil, http.StatusOK)
+	run := func(ctx context.Context, options []OperationOption) (any, error) {
+		if body.Cascade {
+			options = append(options, WithCascade())
+		}
+		restarted, err := p.env.RestartComponent(ctx, body.ComponentID, options...)
+		if err != nil {
+			return nil, err
+		}
+
+		return PostRestartResponse{Restarted: restarted}, nil
+	}
+	apiRun(p.env, p.jobs, writer, request, OperationRestartComponent, body.ComponentID, run)
 }
 
-// postStopHandler handles requests to stop a specific component within the environment.
-type postStopHandler struct {
+// postExecHandler handles requests to execute a command in a component, see Executor.
+type postExecHandler struct {
 	env *Environment
 }
 
-// postStopRequest defines the expected request body for stopping a component.
-type postStopRequest struct {
-	ComponentID string `json:"component_id"`
+// PostExecRequest defines the expected request body for executing a command in a component.
+type PostExecRequest struct {
+	ComponentID string   `json:"component_id"`
+	Command     []string `json:"command"`
 }
 
-func (p postStopHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	body := postStopRequest{}
+// PostExecResponse defines the response body for executing a command in a component,
+// including the exit code of the command and the output the component wrote while the command ran.
+type PostExecResponse struct {
+	ExitCode int            `json:"exit_code"`
+	Output   []OutputRecord `json:"output"`
+}
+
+func (p postExecHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	body := PostExecRequest{}
 	if !apiParse(p.env, writer, request, &body) {
 		return
 	}
+	if len(body.Command) == 0 {
+		apiError(p.env, writer, "missing command", http.StatusBadRequest)
+		return
+	}
 
-	err := p.env.StopComponent(request.Context(), body.ComponentID)
+	reader := p.env.Output(ForComponents(body.ComponentID), FromTime(time.Now()))
+	response := PostExecResponse{Output: []OutputRecord{}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for record := range reader.Records() {
+			response.Output = append(response.Output, record)
+		}
+	}()
+
+	exitCode, err := p.env.ExecComponent(request.Context(), body.ComponentID, body.Command)
+	reader.finish()
+	<-done
+	_ = reader.Close()
 	if err != nil {
+		var notSupported ErrExecNotSupported
+		if errors.As(err, &notSupported) {
+			apiError(p.env, writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var invalidID ErrInvalidComponentID
+		if errors.As(err, &invalidID) {
+			apiError(p.env, writer, err.Error(), http.StatusNotFound)
+			return
+		}
 		apiError(p.env, writer, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	apiSuccess(p.env, writer, nil, http.StatusOK)
+	response.ExitCode = exitCode
+	apiSuccess(p.env, writer, response, http.StatusOK)
 }
 
 // getOutputHandler handles requests to stream the output from the environment or components.
+// The output can be filtered using query parameters, see apiReaderOptions. Clients that do not keep up with the output
+// receive markers of the lines they missed, or are disconnected if the disconnect_slow query parameter is true.
+// Output is streamed as NDJSON records, server-sent events with a JSON record as data, or the legacy tagged text
+// format, see outputFormat. Text is rendered with ANSI escape codes in the legacy format and plain in any other
+// format, unless the color query parameter requests otherwise, see apiOutputRendering.
 type getOutputHandler struct {
 	env *Environment
 }
 
 // ServeHTTP implements the http.Handler interface for getOutputHandler, streaming output to the client.
+// The format of the stream is negotiated, see outputFormat.
 func (g getOutputHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	writer.Header().Set(accessControl, accessControlValue)
-	reader := g.env.Output()
+	format, err := outputFormat(request)
+	if err != nil {
+		apiError(g.env, writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defaultRendering := OutputRenderingPlain
+	if format == outputFormatLegacy {
+		defaultRendering = OutputRenderingANSI
+	}
+	rendering, err := apiOutputRendering(request, defaultRendering)
+	if err != nil {
+		apiError(g.env, writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	options, err := apiReaderOptions(request)
+	if err != nil {
+		apiError(g.env, writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if value := request.URL.Query().Get(disconnectSlowParam); value != "" {
+		disconnect, err := strconv.ParseBool(value)
+		if err != nil {
+			apiError(g.env, writer, fmt.Sprintf("invalid %s parameter %s", disconnectSlowParam, value), http.StatusBadRequest)
+			return
+		}
+		if disconnect {
+			options = append(options, DisconnectIfSlow())
+		}
+	}
+
+	switch format {
+	case outputFormatNDJSON:
+		writer.Header().Set(contentType, applicationNDJSON)
+	case outputFormatSSE:
+		writer.Header().Set(contentType, textEventStream)
+		writer.Header().Set(cacheControl, noCache)
+	}
+	reader := g.env.Output(options...)
+	defer func() {
+		if dropped := reader.Dropped(); dropped > 0 {
+			g.env.Logger(LogLevelDebug, fmt.Sprintf("output stream reader dropped %d lines", dropped))
+		}
+	}()
 
-	ch := reader.Chan()
+	ch := reader.Records()
 
 	for {
 		select {
-		case data := <-ch:
-			_, err := writer.Write(data)
+		case record, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := formatOutputRecord(format, rendering, record)
+			if err != nil {
+				g.env.Logger(LogLevelError, fmt.Sprintf("could not marshal output record: %v", err))
+				continue
+			}
+			_, err = writer.Write(data)
 			if err != nil {
 				if !errors.Is(err, context.Canceled) {
 					g.env.Logger(LogLevelError, fmt.Sprintf("could not write output stream response: %v", err))
@@ -247,6 +703,289 @@ func (g getOutputHandler) ServeHTTP(writer http.ResponseWriter, request *http.Re
 	}
 }
 
+// getOutputSearchHandler handles requests to search the output kept in memory.
+// Matching lines are filtered using the same query parameters as the /output API, see apiReaderOptions,
+// and the context query parameter sets how many lines of the same component to include around each match.
+// Text is plain unless the color query parameter requests otherwise, see apiOutputRendering.
+type getOutputSearchHandler struct {
+	env *Environment
+}
+
+// GetOutputSearchResponse is the response body of the /output/search API.
+type GetOutputSearchResponse struct {
+	Matches []OutputMatch `json:"matches"`
+}
+
+func (g getOutputSearchHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	options, err := apiReaderOptions(request)
+	if err != nil {
+		apiError(g.env, writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rendering, err := apiOutputRendering(request, OutputRenderingPlain)
+	if err != nil {
+		apiError(g.env, writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	contextLines := 0
+	if value := request.URL.Query().Get(contextParam); value != "" {
+		contextLines, err = strconv.Atoi(value)
+		if err != nil || contextLines < 0 {
+			apiError(g.env, writer, fmt.Sprintf("invalid %s parameter %s", contextParam, value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	matches := g.env.SearchOutput(contextLines, options...)
+	for i := range matches {
+		matches[i].Record = matches[i].Record.rendered(rendering)
+		for j := range matches[i].Before {
+			matches[i].Before[j] = matches[i].Before[j].rendered(rendering)
+		}
+		for j := range matches[i].After {
+			matches[i].After[j] = matches[i].After[j].rendered(rendering)
+		}
+	}
+	apiSuccess(g.env, writer, GetOutputSearchResponse{Matches: matches}, http.StatusOK)
+}
+
+// getOutputDownloadHandler handles requests to download the log files of the environment as a zip archive,
+// see WithLogFiles.
+type getOutputDownloadHandler struct {
+	env *Environment
+}
+
+func (g getOutputDownloadHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	files := g.env.outputManager.logFiles()
+	if files == nil {
+		apiError(g.env, writer, "log files are not enabled for this environment", http.StatusNotFound)
+		return
+	}
+
+	writer.Header().Set(contentType, applicationZip)
+	writer.Header().Set(contentDisposition, fmt.Sprintf("attachment; filename=%q", g.env.id+"-logs.zip"))
+	writer.WriteHeader(http.StatusOK)
+	err := files.zip(writer)
+	if err != nil {
+		g.env.Logger(LogLevelError, fmt.Sprintf("could not write log files archive response: %v", err))
+	}
+}
+
+// apiReaderOptions parses the query parameters filtering output into reader options:
+// - lines: Only the last lines of output kept in memory that match the other filters.
+// - since, until: Only output written within a time range, as RFC 3339 timestamps.
+// - component: Only output of the given components, either repeated or comma separated.
+// - stream: Only output written to the given streams, either repeated or comma separated.
+// - contains: Only lines containing a substring.
+// - regex: Only lines matching a regular expression.
+// - alerts: If true, only lines that raised an alert, see WithAlertRules.
+func apiReaderOptions(request *http.Request) ([]ReaderOption, error) {
+	var options []ReaderOption
+	query := request.URL.Query()
+	if value := query.Get(linesParam); value != "" {
+		lines, err := strconv.Atoi(value)
+		if err != nil || lines < 0 {
+			return nil, fmt.Errorf("invalid %s parameter %s", linesParam, value)
+		}
+		options = append(options, FromLastLines(lines))
+	}
+	if value := query.Get(sinceParam); value != "" {
+		since, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter %s", sinceParam, value)
+		}
+		options = append(options, FromTime(since))
+	}
+	if value := query.Get(untilParam); value != "" {
+		until, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter %s", untilParam, value)
+		}
+		options = append(options, UntilTime(until))
+	}
+	if ids := apiListParam(query[componentParam]); len(ids) > 0 {
+		options = append(options, ForComponents(ids...))
+	}
+	if values := apiListParam(query[streamParam]); len(values) > 0 {
+		streams := make([]OutputStream, 0, len(values))
+		for _, value := range values {
+			stream := OutputStream(value)
+			if stream != OutputStreamStdout && stream != OutputStreamStderr {
+				return nil, fmt.Errorf("invalid %s parameter %s", streamParam, value)
+			}
+			streams = append(streams, stream)
+		}
+		options = append(options, ForStreams(streams...))
+	}
+	if value := query.Get(containsParam); value != "" {
+		options = append(options, Containing(value))
+	}
+	if value := query.Get(regexParam); value != "" {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter %s: %w", regexParam, value, err)
+		}
+		options = append(options, Matching(pattern))
+	}
+	if value := query.Get(alertsParam); value != "" {
+		alerts, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter %s", alertsParam, value)
+		}
+		if alerts {
+			options = append(options, Alerting())
+		}
+	}
+	return options, nil
+}
+
+// apiListParam returns the values of a query parameter that may be either repeated or comma separated.
+func apiListParam(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// outputFormat returns the format requested for the /output API. The format query parameter takes precedence,
+// and is one of legacy, ndjson or sse. Otherwise, an Accept header of application/x-ndjson or text/event-stream
+// selects NDJSON or server-sent events respectively. By default, the legacy tagged text format is used.
+func outputFormat(request *http.Request) (string, error) {
+	if value := request.URL.Query().Get(formatParam); value != "" {
+		switch value {
+		case outputFormatLegacy, outputFormatNDJSON, outputFormatSSE:
+			return value, nil
+		}
+		return "", fmt.Errorf("invalid %s parameter %s", formatParam, value)
+	}
+
+	for _, value := range request.Header.Values(accept) {
+		for _, mediaType := range strings.Split(value, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			switch strings.ToLower(strings.TrimSpace(mediaType)) {
+			case applicationNDJSON, "application/ndjson":
+				return outputFormatNDJSON, nil
+			case textEventStream:
+				return outputFormatSSE, nil
+			}
+		}
+	}
+	return outputFormatLegacy, nil
+}
+
+// apiOutputRendering returns the rendering of output text requested via the color query parameter,
+// one of plain, ansi or html, or defaultRendering if none is requested.
+func apiOutputRendering(request *http.Request, defaultRendering OutputRendering) (OutputRendering, error) {
+	value := request.URL.Query().Get(colorParam)
+	if value == "" {
+		return defaultRendering, nil
+	}
+	rendering, err := ParseOutputRendering(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s parameter %s", colorParam, value)
+	}
+	return rendering, nil
+}
+
+// formatOutputRecord encodes a single output record in the given format and rendering of the /output API.
+func formatOutputRecord(format string, rendering OutputRendering, record OutputRecord) ([]byte, error) {
+	if format == outputFormatLegacy {
+		return record.legacy(rendering), nil
+	}
+
+	data, err := json.Marshal(record.rendered(rendering))
+	if err != nil {
+		return nil, err
+	}
+	if format == outputFormatSSE {
+		return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", outputEvent, data)), nil
+	}
+	return append(data, '\n'), nil
+}
+
+// getJobHandler handles requests to retrieve the progress of an asynchronous operation.
+type getJobHandler struct {
+	env  *Environment
+	jobs *jobManager
+}
+
+func (g getJobHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	job, err := g.jobs.get(mux.Vars(request)["id"])
+	if err != nil {
+		apiError(g.env, writer, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	apiSuccess(g.env, writer, job, http.StatusOK)
+}
+
+// deleteJobHandler handles requests to cancel an asynchronous operation.
+// The operation stops as soon as possible, and the job status becomes canceled once it does.
+type deleteJobHandler struct {
+	env  *Environment
+	jobs *jobManager
+}
+
+func (d deleteJobHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	job, err := d.jobs.cancel(mux.Vars(request)["id"])
+	if err != nil {
+		apiError(d.env, writer, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	apiSuccess(d.env, writer, job, http.StatusOK)
+}
+
+// getEventsHandler handles requests to stream the lifecycle events of the environment as server-sent events.
+type getEventsHandler struct {
+	env *Environment
+}
+
+// ServeHTTP implements the http.Handler interface for getEventsHandler, streaming each event to the client
+// with the event type as the SSE event name and the JSON encoded Event as its data.
+func (g getEventsHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	subscription := g.env.Subscribe()
+	defer func() {
+		_ = subscription.Close()
+	}()
+
+	writer.Header().Set(contentType, textEventStream)
+	writer.Header().Set(cacheControl, noCache)
+	writer.WriteHeader(http.StatusOK)
+	if f, ok := writer.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	ch := subscription.Chan()
+	for {
+		select {
+		case event := <-ch:
+			data, err := json.Marshal(event)
+			if err != nil {
+				g.env.Logger(LogLevelError, fmt.Sprintf("could not marshal event: %v", err))
+				continue
+			}
+			_, err = fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event.Type, data)
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					g.env.Logger(LogLevelError, fmt.Sprintf("could not write events stream response: %v", err))
+				}
+				continue
+			}
+			if f, ok := writer.(http.Flusher); ok {
+				f.Flush()
+			}
+		case <-request.Context().Done():
+			return
+		}
+	}
+}
+
 // apiParse is a helper function to parse the JSON body of a request into a target struct.
 // It returns true if parsing is successful, false otherwise.
 func apiParse(b *Environment, writer http.ResponseWriter, request *http.Request, target any) bool {
@@ -273,21 +1012,89 @@ func apiParse(b *Environment, writer http.ResponseWriter, request *http.Request,
 	return true
 }
 
-// apiErrorResponse represents the json response body returned in case of an error
-type apiErrorResponse struct {
-	Error string `json:"error"`
+// apiOperationOptions returns the options of a lifecycle operation requested via the API.
+// By default, the operation waits for any running operation to finish. If the request sets the wait
+// query parameter to false, the operation fails immediately instead, see apiOperationError.
+func apiOperationOptions(request *http.Request) []OperationOption {
+	if request.URL.Query().Get(waitParam) == "false" {
+		return []OperationOption{WithoutWaiting()}
+	}
+	return nil
+}
+
+// apiRun is a helper function to run a lifecycle operation requested via the API and send its result.
+// If the request sets the async query parameter to true, the operation runs in the background as a job,
+// and the job is sent immediately with an accepted status. Its progress is then available via the /jobs/{id} endpoint.
+func apiRun(
+	b *Environment,
+	jobs *jobManager,
+	writer http.ResponseWriter,
+	request *http.Request,
+	operation Operation,
+	componentID string,
+	fn jobFunc,
+) {
+	if request.URL.Query().Get(asyncParam) == "true" {
+		job, err := jobs.start(operation, componentID, apiOperationOptions(request), fn)
+		if err != nil {
+			apiError(b, writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		apiSuccess(b, writer, job, http.StatusAccepted)
+		return
+	}
+
+	result, err := fn(request.Context(), apiOperationOptions(request))
+	if err != nil {
+		apiOperationError(b, writer, err)
+		return
+	}
+
+	apiSuccess(b, writer, result, http.StatusOK)
+}
+
+// apiOperationError is a helper function to send an error response for a failed lifecycle operation.
+// If another operation is in progress, it responds with a conflict status and the running operation as details.
+// If applying state failed, the *ApplyError is included as details.
+func apiOperationError(b *Environment, writer http.ResponseWriter, err error) {
+	var inProgressErr ErrOperationInProgress
+	if errors.As(err, &inProgressErr) {
+		apiErrorWithDetails(b, writer, err.Error(), inProgressErr.Running, http.StatusConflict)
+		return
+	}
+
+	var applyErr *ApplyError
+	if errors.As(err, &applyErr) {
+		apiErrorWithDetails(b, writer, err.Error(), applyErr, http.StatusInternalServerError)
+		return
+	}
+
+	apiError(b, writer, err.Error(), http.StatusInternalServerError)
+}
+
+// ErrorResponse represents the json response body returned in case of an error.
+// Details optionally holds structured information about the error, such as an *ApplyError.
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Details any    `json:"details,omitempty"`
 }
 
 // apiError is a helper function to send an error response with a specific HTTP status code.
 func apiError(b *Environment, writer http.ResponseWriter, error string, status int) {
+	apiErrorWithDetails(b, writer, error, nil, status)
+}
+
+// apiErrorWithDetails is a helper function to send an error response with a specific HTTP status code,
+// including structured details about the error.
+func apiErrorWithDetails(b *Environment, writer http.ResponseWriter, error string, details any, status int) {
 	if status >= 500 && !strings.Contains(error, "context canceled") {
 		b.Logger(LogLevelError, fmt.Sprintf("failed to serve request with status %d: %s", status, error))
 	}
-	writer.Header().Set(accessControl, accessControlValue)
 	writer.Header().Set(contentType, applicationJSON)
 	writer.WriteHeader(status)
 
-	response := apiErrorResponse{Error: error}
+	response := ErrorResponse{Error: error, Details: details}
 	data, err := json.Marshal(response)
 	if err != nil {
 		b.Logger(LogLevelError, fmt.Sprintf("could not marshal fail response: %v", err))
@@ -321,7 +1128,6 @@ func apiSuccess(b *Environment, writer http.ResponseWriter, body any, status int
 		}
 	}
 
-	writer.Header().Set(accessControl, accessControlValue)
 	writer.Header().Set(contentType, applicationJSON)
 	writer.WriteHeader(status)
 	_, err := writer.Write(data)
@@ -337,8 +1143,8 @@ func apiRoute(router *mux.Router, method, path string, handler http.Handler) {
 }
 
 // optionsHandler is a pre-configured HTTP handler for responding to CORS preflight requests.
+// The allowed origin is set by the Server, see WithAllowedOrigins.
 func optionsHandler(writer http.ResponseWriter, _ *http.Request) {
-	writer.Header().Set(accessControl, accessControlValue)
 	writer.Header().Set(accessControlAllowHeaders, accessControlAllowHeadersValue)
 	writer.Header().Set(accessControlAllowMethods, accessControlAllowMethodsValue)
 	writer.WriteHeader(http.StatusOK)
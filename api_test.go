@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -54,22 +55,32 @@ func TestAPI(t *testing.T) {
 	assert.Equal(t, "component", getStatusResponse.Components[0][0].ID)
 	assert.Equal(t, "mock", getStatusResponse.Components[0][0].Type)
 
-	status = call(postStartHandler{env: env}, postStartRequest{ComponentID: "invalid"}, nil)
+	status = call(postStartHandler{env: env}, PostStartRequest{ComponentID: "invalid"}, nil)
 	assert.Equal(t, http.StatusInternalServerError, status)
-	status = call(postStartHandler{env: env}, postStartRequest{ComponentID: "component"}, nil)
+	postStartRes := PostStartResponse{}
+	status = call(postStartHandler{env: env}, PostStartRequest{ComponentID: "component", WithDependencies: true}, &postStartRes)
 	assert.Equal(t, http.StatusOK, status)
 	assert.Equal(t, ComponentStatusRunning, component.status)
+	assert.Equal(t, []string{"component"}, postStartRes.Started)
 
-	status = call(postStopHandler{env: env}, postStopRequest{ComponentID: "invalid"}, nil)
+	status = call(postStopHandler{env: env}, PostStopRequest{ComponentID: "invalid"}, nil)
 	assert.Equal(t, http.StatusInternalServerError, status)
-	status = call(postStopHandler{env: env}, postStopRequest{ComponentID: "component"}, nil)
+	postStopRes := PostStopResponse{}
+	status = call(postStopHandler{env: env}, PostStopRequest{ComponentID: "component", WithDependents: true}, &postStopRes)
 	assert.Equal(t, http.StatusOK, status)
 	assert.Equal(t, ComponentStatusStopped, component.status)
+	assert.Equal(t, []string{"component"}, postStopRes.Stopped)
 
-	status = call(postApplyHandler{env: env}, postApplyRequest{EnabledComponentIDs: []string{"component"}}, nil)
+	plan := Plan{}
+	status = call(postPlanHandler{env: env}, PostPlanRequest{EnabledComponentIDs: []string{"component"}}, &plan)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []ComponentAction{ComponentActionPrepare, ComponentActionStart}, plan.Components[0].Actions)
+	assert.Equal(t, ComponentStatusStopped, component.status)
+
+	status = call(postApplyHandler{env: env}, PostApplyRequest{EnabledComponentIDs: []string{"component"}}, nil)
 	assert.Equal(t, http.StatusOK, status)
 	assert.Equal(t, ComponentStatusRunning, component.status)
-	status = call(postApplyHandler{env: env}, postApplyRequest{EnabledComponentIDs: []string{}}, nil)
+	status = call(postApplyHandler{env: env}, PostApplyRequest{EnabledComponentIDs: []string{}}, nil)
 	assert.Equal(t, http.StatusOK, status)
 	assert.Equal(t, ComponentStatusStopped, component.status)
 
@@ -77,7 +88,7 @@ func TestAPI(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, ComponentStatusRunning, component.status)
 	assert.False(t, component.cleanupCalled)
-	status = call(postStopAllHandler{env: env}, postStopAllRequest{Cleanup: true}, nil)
+	status = call(postStopAllHandler{env: env}, PostStopAllRequest{Cleanup: true}, nil)
 	assert.Equal(t, http.StatusOK, status)
 	assert.True(t, component.cleanupCalled)
 
@@ -95,6 +106,19 @@ func TestAPI(t *testing.T) {
 	outputHandler.ServeHTTP(res, req)
 	assert.True(t, time.Since(startTime) >= time.Second)
 
+	req = httptest.NewRequest(http.MethodGet, "/output?lines=abc", nil)
+	res = httptest.NewRecorder()
+	outputHandler.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	req = httptest.NewRequest(http.MethodGet, "/output?since=yesterday", nil)
+	res = httptest.NewRecorder()
+	outputHandler.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	req = httptest.NewRequest(http.MethodGet, "/output?disconnect_slow=maybe", nil)
+	res = httptest.NewRecorder()
+	outputHandler.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
 	wHandler := newWebHandler()
 	req = httptest.NewRequest(http.MethodGet, "/", nil)
 	res = httptest.NewRecorder()
@@ -110,3 +134,177 @@ func TestAPI(t *testing.T) {
 	optionsHandler(res, req)
 	assert.Equal(t, http.StatusOK, res.Code)
 }
+
+func TestAPIOutputFormats(t *testing.T) {
+	component := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+	component.w.WriteRecord(OutputRecord{
+		Time:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		Stream: OutputStreamStderr,
+		Level:  LogLevelInfo,
+		Text:   "message",
+	})
+
+	stream := func(target string, acceptHeader string) *httptest.ResponseRecorder {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+		if acceptHeader != "" {
+			req.Header.Set("Accept", acceptHeader)
+		}
+		res := httptest.NewRecorder()
+		getOutputHandler{env: env}.ServeHTTP(res, req)
+		return res
+	}
+	record := `{"component_id":"component","time":"2000-01-01T00:00:00Z","stream":"stderr","level":"info","text":"message"}`
+
+	res := stream("/output?format=ndjson", "")
+	assert.Equal(t, "application/x-ndjson", res.Header().Get("Content-Type"))
+	assert.Equal(t, record+"\n", res.Body.String())
+
+	res = stream("/output", "application/x-ndjson")
+	assert.Equal(t, record+"\n", res.Body.String())
+
+	res = stream("/output", "text/html, text/event-stream;q=0.9")
+	assert.Equal(t, "text/event-stream", res.Header().Get("Content-Type"))
+	assert.Equal(t, "event: output\ndata: "+record+"\n\n", res.Body.String())
+
+	res = stream("/output?format=legacy", "text/event-stream")
+	assert.Contains(t, res.Body.String(), "<component>component<time>2000-01-01T")
+	assert.Contains(t, res.Body.String(), "<msg>\u001B[31mmessage\u001B[39m\n")
+
+	res = stream("/output", "")
+	assert.Contains(t, res.Body.String(), "<component>component<time>2000-01-01T")
+
+	res = stream("/output?format=xml", "")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestAPIOutputSearch(t *testing.T) {
+	api := &mockComponent{}
+	db := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("api", api).AddComponent("db", db))
+	assert.NoError(t, err)
+	api.w.WriteString("api listening")
+	db.w.WriteString("db failed to connect")
+	api.w.WriteRecord(OutputRecord{Stream: OutputStreamStderr, Text: "api failed to connect"})
+	api.w.WriteString("api retrying")
+
+	search := func(target string) (int, GetOutputSearchResponse) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		res := httptest.NewRecorder()
+		getOutputSearchHandler{env: env}.ServeHTTP(res, req)
+		var response GetOutputSearchResponse
+		if res.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &response))
+		}
+		return res.Code, response
+	}
+
+	code, response := search("/output/search?regex=failed+to+%5Cw%2B&context=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Matches, 2)
+	assert.Equal(t, "db failed to connect", response.Matches[0].Record.Text)
+	assert.Empty(t, response.Matches[0].Before)
+	assert.Equal(t, "api failed to connect", response.Matches[1].Record.Text)
+	assert.Equal(t, []string{"api listening"}, texts(response.Matches[1].Before))
+	assert.Equal(t, []string{"api retrying"}, texts(response.Matches[1].After))
+
+	code, response = search("/output/search?component=api,db&stream=stderr")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Matches, 1)
+	assert.Equal(t, "api", response.Matches[0].Record.ComponentID)
+
+	code, response = search("/output/search?component=db&component=api&contains=retrying")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Matches, 1)
+
+	for _, target := range []string{
+		"/output/search?regex=(",
+		"/output/search?stream=stdin",
+		"/output/search?until=tomorrow",
+		"/output/search?context=-1",
+	} {
+		code, _ = search(target)
+		assert.Equal(t, http.StatusBadRequest, code, target)
+	}
+}
+
+func TestAPIApplyError(t *testing.T) {
+	component := &mockComponent{shouldFail: true}
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddLayer(map[string]Component{"component": component}),
+	)
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(PostApplyRequest{EnabledComponentIDs: []string{"component"}})
+	assert.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/apply", bytes.NewBuffer(data))
+	req.Header.Set(contentType, applicationJSON)
+	res := httptest.NewRecorder()
+	postApplyHandler{env: env}.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+
+	var response struct {
+		Error   string     `json:"error"`
+		Details ApplyError `json:"details"`
+	}
+	err = json.Unmarshal(res.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response.Error, "start error")
+	assert.Equal(t, FailurePolicyFailFast, response.Details.Policy)
+	assert.Len(t, response.Details.Components, 1)
+	assert.Equal(t, "component", response.Details.Components[0].ID)
+	assert.Equal(t, ComponentResultFailed, response.Details.Components[0].Result)
+}
+
+type execComponent struct {
+	*mockComponent
+}
+
+func (e execComponent) Exec(_ context.Context, cmd []string) (int, error) {
+	e.w.WriteString("executed " + strings.Join(cmd, " "))
+	return 3, nil
+}
+
+func TestAPIExec(t *testing.T) {
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().
+			AddComponent("component", execComponent{mockComponent: &mockComponent{}}).
+			AddComponent("other", &mockComponent{}),
+	)
+	assert.NoError(t, err)
+
+	call := func(request PostExecRequest) (int, []byte) {
+		data, err := json.Marshal(request)
+		assert.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/exec", bytes.NewBuffer(data))
+		req.Header.Set(contentType, applicationJSON)
+		res := httptest.NewRecorder()
+		postExecHandler{env: env}.ServeHTTP(res, req)
+		return res.Code, res.Body.Bytes()
+	}
+
+	status, body := call(PostExecRequest{ComponentID: "component", Command: []string{"echo", "hello"}})
+	assert.Equal(t, http.StatusOK, status)
+	var response PostExecResponse
+	assert.NoError(t, json.Unmarshal(body, &response))
+	assert.Equal(t, 3, response.ExitCode)
+	if assert.Len(t, response.Output, 1) {
+		assert.Equal(t, "executed echo hello", response.Output[0].Text)
+	}
+
+	status, body = call(PostExecRequest{ComponentID: "other", Command: []string{"echo"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, string(body), "component other does not support executing commands")
+
+	status, _ = call(PostExecRequest{ComponentID: "component"})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, body = call(PostExecRequest{ComponentID: "invalid", Command: []string{"echo"}})
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Contains(t, string(body), "component id 'invalid' is invalid: not found")
+}
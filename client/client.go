@@ -0,0 +1,379 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package client provides a typed client for the API of a running ENVITE daemon, see envite.Server.
+// The API is described by the OpenAPI document the daemon serves at /openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/perimeterx/envite"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tokenParam      = "token"
+	contentType     = "Content-Type"
+	authorization   = "Authorization"
+	bearerPrefix    = "Bearer "
+	applicationJSON = "application/json"
+
+	// unixSocketHost is the host of request URLs sent to a Unix domain socket, which is ignored.
+	unixSocketHost = "envite"
+)
+
+// Client sends requests to the API of a running ENVITE daemon. It is safe for concurrent use.
+type Client struct {
+	baseURL    url.URL
+	token      string
+	httpClient *http.Client
+}
+
+// Option is a function type for configuring a Client.
+type Option func(*Client)
+
+// WithToken is an Option that authenticates requests using token, for daemons that require one,
+// see envite.WithAuthToken. It takes precedence over a token embedded in the address of the daemon.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient is an Option that sends requests using httpClient, such as a client trusting the certificate of
+// a daemon serving HTTPS. For a daemon listening on a Unix domain socket, httpClient must dial the socket,
+// see envite.NewUnixSocketClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New creates a new Client for the daemon at address, such as http://localhost:4005, or unix:///tmp/envite.sock
+// for a daemon listening on a Unix domain socket. The URL a daemon prints at startup may be used as is,
+// including its token, see envite.Server.URL.
+func New(address string, options ...Option) (*Client, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %w", address, err)
+	}
+
+	c := &Client{token: u.Query().Get(tokenParam)}
+	for _, option := range options {
+		option(c)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid address %s: missing host", address)
+		}
+		c.baseURL = url.URL{Scheme: u.Scheme, Host: u.Host}
+	case "unix":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid address %s: missing socket path", address)
+		}
+		c.baseURL = url.URL{Scheme: "http", Host: unixSocketHost}
+		if c.httpClient == nil {
+			c.httpClient = envite.NewUnixSocketClient(u.Path)
+		}
+	default:
+		return nil, fmt.Errorf("invalid address %s: scheme must be http, https or unix", address)
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	return c, nil
+}
+
+// Status returns the status of the environment and all of its components.
+func (c *Client) Status(ctx context.Context) (envite.GetStatusResponse, error) {
+	var response envite.GetStatusResponse
+	err := c.call(ctx, http.MethodGet, "/status", nil, nil, &response)
+	return response, err
+}
+
+// StartComponent starts a component, returning the IDs of the components that were started.
+func (c *Client) StartComponent(
+	ctx context.Context,
+	request envite.PostStartRequest,
+) (envite.PostStartResponse, error) {
+	var response envite.PostStartResponse
+	err := c.call(ctx, http.MethodPost, "/start_component", nil, request, &response)
+	return response, err
+}
+
+// StopComponent stops a component, returning the IDs of the components that were stopped.
+func (c *Client) StopComponent(
+	ctx context.Context,
+	request envite.PostStopRequest,
+) (envite.PostStopResponse, error) {
+	var response envite.PostStopResponse
+	err := c.call(ctx, http.MethodPost, "/stop_component", nil, request, &response)
+	return response, err
+}
+
+// RestartComponent restarts a component, or starts it if it is not running,
+// returning the IDs of the components that were restarted.
+func (c *Client) RestartComponent(
+	ctx context.Context,
+	request envite.PostRestartRequest,
+) (envite.PostRestartResponse, error) {
+	var response envite.PostRestartResponse
+	err := c.call(ctx, http.MethodPost, "/restart_component", nil, request, &response)
+	return response, err
+}
+
+// Apply starts the enabled components of request and stops all other components.
+// If any component fails, the returned ErrResponse holds the outcome of each component as details.
+func (c *Client) Apply(ctx context.Context, request envite.PostApplyRequest) error {
+	return c.call(ctx, http.MethodPost, "/apply", nil, request, nil)
+}
+
+// Plan returns the actions applying the enabled components of request would take.
+func (c *Client) Plan(ctx context.Context, request envite.PostPlanRequest) (envite.Plan, error) {
+	var response envite.Plan
+	err := c.call(ctx, http.MethodPost, "/plan", nil, request, &response)
+	return response, err
+}
+
+// StopAll stops all components, and cleans them up if request says so.
+func (c *Client) StopAll(ctx context.Context, request envite.PostStopAllRequest) error {
+	return c.call(ctx, http.MethodPost, "/stop_all", nil, request, nil)
+}
+
+// Exec executes a command in a component that supports it, see envite.Executor. It returns the exit code of
+// the command and the output the component wrote while the command ran.
+func (c *Client) Exec(ctx context.Context, request envite.PostExecRequest) (envite.PostExecResponse, error) {
+	var response envite.PostExecResponse
+	err := c.call(ctx, http.MethodPost, "/exec", nil, request, &response)
+	return response, err
+}
+
+// StartJob runs a lifecycle operation in the background and returns its job without waiting for it.
+// The operation is one of envite.OperationApply, envite.OperationStopAll, envite.OperationStartComponent,
+// envite.OperationStopComponent or envite.OperationRestartComponent, and request is its request body,
+// such as envite.PostStartRequest for envite.OperationStartComponent. See Job for the progress of the job.
+func (c *Client) StartJob(ctx context.Context, operation envite.Operation, request any) (envite.Job, error) {
+	switch operation {
+	case envite.OperationApply, envite.OperationStopAll, envite.OperationStartComponent,
+		envite.OperationStopComponent, envite.OperationRestartComponent:
+	default:
+		return envite.Job{}, fmt.Errorf("operation %s cannot run as a job", operation)
+	}
+
+	var response envite.Job
+	query := url.Values{"async": []string{"true"}}
+	err := c.call(ctx, http.MethodPost, "/"+string(operation), query, request, &response)
+	return response, err
+}
+
+// Job returns the progress of a job started using StartJob.
+func (c *Client) Job(ctx context.Context, id string) (envite.Job, error) {
+	var response envite.Job
+	err := c.call(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, nil, &response)
+	return response, err
+}
+
+// CancelJob cancels a job started using StartJob, and returns it. The status of the job becomes canceled
+// once its operation stops.
+func (c *Client) CancelJob(ctx context.Context, id string) (envite.Job, error) {
+	var response envite.Job
+	err := c.call(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(id), nil, nil, &response)
+	return response, err
+}
+
+// OutputQuery filters and renders the output of components, see Client.Output and Client.SearchOutput.
+//
+// Fields:
+// - LastLines: Only the last lines of output kept in memory that match the other filters. Zero means all of them.
+// - Since: Only output written since this time, unless zero.
+// - Until: Only output written until this time, unless zero.
+// - Components: Only output of these components. Empty means all components.
+// - Streams: Only output written to these streams. Empty means all streams.
+// - Contains: Only lines containing this substring, unless empty.
+// - Regex: Only lines matching this regular expression, unless empty.
+// - Alerts: Only lines that raised an alert, see envite.WithAlertRules.
+// - Color: How colors are rendered in text. Defaults to envite.OutputRenderingPlain.
+type OutputQuery struct {
+	LastLines  int
+	Since      time.Time
+	Until      time.Time
+	Components []string
+	Streams    []envite.OutputStream
+	Contains   string
+	Regex      string
+	Alerts     bool
+	Color      envite.OutputRendering
+}
+
+// values returns the query parameters of q.
+func (q OutputQuery) values() url.Values {
+	values := url.Values{}
+	if q.LastLines > 0 {
+		values.Set("lines", strconv.Itoa(q.LastLines))
+	}
+	if !q.Since.IsZero() {
+		values.Set("since", q.Since.Format(time.RFC3339Nano))
+	}
+	if !q.Until.IsZero() {
+		values.Set("until", q.Until.Format(time.RFC3339Nano))
+	}
+	if len(q.Components) > 0 {
+		values.Set("component", strings.Join(q.Components, ","))
+	}
+	for _, stream := range q.Streams {
+		values.Add("stream", string(stream))
+	}
+	if q.Contains != "" {
+		values.Set("contains", q.Contains)
+	}
+	if q.Regex != "" {
+		values.Set("regex", q.Regex)
+	}
+	if q.Alerts {
+		values.Set("alerts", "true")
+	}
+	if q.Color != "" {
+		values.Set("color", string(q.Color))
+	}
+	return values
+}
+
+// SearchOutput returns the lines of output kept in memory that match query, each with up to contextLines lines
+// of the same component around it.
+func (c *Client) SearchOutput(ctx context.Context, query OutputQuery, contextLines int) ([]envite.OutputMatch, error) {
+	values := query.values()
+	if contextLines > 0 {
+		values.Set("context", strconv.Itoa(contextLines))
+	}
+	var response envite.GetOutputSearchResponse
+	err := c.call(ctx, http.MethodGet, "/output/search", values, nil, &response)
+	return response.Matches, err
+}
+
+// Output streams the output matching query, starting with the matching lines kept in memory, and then following
+// new lines as they are written. The stream ends once ctx is canceled or the stream is closed.
+func (c *Client) Output(ctx context.Context, query OutputQuery) (*OutputStream, error) {
+	values := query.values()
+	values.Set("format", "ndjson")
+	res, err := c.send(ctx, http.MethodGet, "/output", values, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &OutputStream{body: res.Body, decoder: json.NewDecoder(res.Body)}, nil
+}
+
+// OutputStream is a stream of output records, see Client.Output.
+type OutputStream struct {
+	body    io.ReadCloser
+	decoder *json.Decoder
+}
+
+// Next blocks until the next record is received, and returns it.
+// It returns io.EOF once the stream ends.
+func (s *OutputStream) Next() (envite.OutputRecord, error) {
+	var record envite.OutputRecord
+	err := s.decoder.Decode(&record)
+	return record, err
+}
+
+// Close closes the stream.
+func (s *OutputStream) Close() error {
+	return s.body.Close()
+}
+
+// call sends a request with a JSON encoded body, unless nil, and decodes the JSON response body into response,
+// unless nil.
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body, response any) error {
+	res, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if response == nil {
+		return nil
+	}
+	err = json.NewDecoder(res.Body).Decode(response)
+	if err != nil {
+		return fmt.Errorf("could not decode response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// send sends a request with a JSON encoded body, unless nil, and returns the response.
+// It returns an ErrResponse if the response status is not successful.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	u := c.baseURL
+	u.Path = path
+	u.RawQuery = query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode request body of %s %s: %w", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set(contentType, applicationJSON)
+	}
+	if c.token != "" {
+		request.Header.Set(authorization, bearerPrefix+c.token)
+	}
+
+	res, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+		return res, nil
+	}
+
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	var response struct {
+		Error   string          `json:"error"`
+		Details json.RawMessage `json:"details"`
+	}
+	data, _ := io.ReadAll(res.Body)
+	if json.Unmarshal(data, &response) != nil || response.Error == "" {
+		response.Error = strings.TrimSpace(string(data))
+	}
+	return nil, ErrResponse{StatusCode: res.StatusCode, Message: response.Error, Details: response.Details}
+}
+
+// ErrResponse is an error type representing an unsuccessful response of the API.
+//
+// Fields:
+// - StatusCode: The HTTP status code of the response.
+// - Message: The error message of the response.
+// - Details: Structured information about the error, such as an envite.ApplyError, if any.
+type ErrResponse struct {
+	StatusCode int
+	Message    string
+	Details    json.RawMessage
+}
+
+func (e ErrResponse) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Message)
+}
@@ -0,0 +1,197 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/perimeterx/envite"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testComponent struct {
+	lock   sync.Mutex
+	status envite.ComponentStatus
+	writer *envite.Writer
+}
+
+func (c *testComponent) Type() string {
+	return "test"
+}
+
+func (c *testComponent) AttachEnvironment(_ context.Context, _ *envite.Environment, writer *envite.Writer) error {
+	c.writer = writer
+	return nil
+}
+
+func (c *testComponent) Prepare(context.Context) error {
+	return nil
+}
+
+func (c *testComponent) Start(context.Context) error {
+	c.setStatus(envite.ComponentStatusRunning)
+	c.writer.Info("started")
+	return nil
+}
+
+func (c *testComponent) Stop(context.Context) error {
+	c.setStatus(envite.ComponentStatusStopped)
+	return nil
+}
+
+func (c *testComponent) Cleanup(context.Context) error {
+	return nil
+}
+
+func (c *testComponent) Status(context.Context) (envite.ComponentStatus, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.status == "" {
+		return envite.ComponentStatusStopped, nil
+	}
+	return c.status, nil
+}
+
+func (c *testComponent) setStatus(status envite.ComponentStatus) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.status = status
+}
+
+func (c *testComponent) Exec(_ context.Context, cmd []string) (int, error) {
+	c.writer.WriteString(strings.Join(cmd, " "))
+	return 1, nil
+}
+
+func (c *testComponent) Config() any {
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	env, err := envite.NewEnvironment(
+		"test-env",
+		envite.NewComponentGraph().AddComponent("db", &testComponent{}).AddComponent("service", &testComponent{}, "db"),
+	)
+	assert.NoError(t, err)
+
+	// socket paths are limited in length, so a short directory is used
+	dir, err := os.MkdirTemp("", "envite")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "envite.sock")
+	server := envite.NewServer("", env, envite.WithUnixSocket(path, 0), envite.WithAuthToken("secret"))
+	done := make(chan error)
+	go func() {
+		done <- server.Start()
+	}()
+	defer func() {
+		assert.NoError(t, server.Close())
+		assert.NoError(t, <-done)
+	}()
+
+	ctx := context.Background()
+	c, err := New("unix://" + path)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, err = c.Status(ctx)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+	var errResponse ErrResponse
+	assert.True(t, errors.As(err, &errResponse))
+	assert.Equal(t, http.StatusUnauthorized, errResponse.StatusCode)
+	assert.Equal(t, "missing or invalid token", errResponse.Message)
+
+	c, err = New("unix://"+path, WithToken("secret"))
+	assert.NoError(t, err)
+	status, err := c.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-env", status.ID)
+
+	plan, err := c.Plan(ctx, envite.PostPlanRequest{EnabledComponentIDs: []string{"db"}})
+	assert.NoError(t, err)
+	assert.Len(t, plan.Changes(), 1)
+
+	started, err := c.StartComponent(ctx, envite.PostStartRequest{ComponentID: "service", WithDependencies: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db", "service"}, started.Started)
+
+	_, err = c.StartComponent(ctx, envite.PostStartRequest{ComponentID: "invalid"})
+	assert.True(t, errors.As(err, &errResponse))
+	assert.Equal(t, http.StatusInternalServerError, errResponse.StatusCode)
+
+	matches, err := c.SearchOutput(ctx, OutputQuery{Components: []string{"service"}, Contains: "started"}, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "service", matches[0].Record.ComponentID)
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream, err := c.Output(streamCtx, OutputQuery{Components: []string{"db"}})
+	assert.NoError(t, err)
+	record, err := stream.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "db", record.ComponentID)
+	assert.Equal(t, "started", record.Text)
+	cancel()
+	assert.NoError(t, stream.Close())
+
+	exec, err := c.Exec(ctx, envite.PostExecRequest{ComponentID: "db", Command: []string{"echo", "hello"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, exec.ExitCode)
+	if assert.Len(t, exec.Output, 1) {
+		assert.Equal(t, "echo hello", exec.Output[0].Text)
+	}
+
+	stopped, err := c.StopComponent(ctx, envite.PostStopRequest{ComponentID: "db", WithDependents: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"service", "db"}, stopped.Stopped)
+
+	job, err := c.StartJob(ctx, envite.OperationApply, envite.PostApplyRequest{EnabledComponentIDs: []string{"db"}})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		job, err = c.Job(ctx, job.ID)
+		return err == nil && job.Status == envite.JobStatusSucceeded
+	}, time.Second, 10*time.Millisecond)
+	_, err = c.StartJob(ctx, envite.OperationStartAll, nil)
+	assert.ErrorContains(t, err, "operation start_all cannot run as a job")
+
+	assert.NoError(t, c.StopAll(ctx, envite.PostStopAllRequest{Cleanup: true}))
+	status, err = c.Status(ctx)
+	assert.NoError(t, err)
+	for _, layer := range status.Components {
+		for _, component := range layer {
+			assert.Equal(t, envite.ComponentStatusStopped, component.Status)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	c, err := New("http://localhost:4005/?token=secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", c.token)
+	assert.Equal(t, "http://localhost:4005", c.baseURL.String())
+
+	c, err = New("https://localhost:4005", WithToken("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, "other", c.token)
+
+	_, err = New("localhost:4005")
+	assert.Error(t, err)
+
+	_, err = New("ftp://localhost")
+	assert.ErrorContains(t, err, "scheme must be http, https or unix")
+
+	_, err = New("unix://")
+	assert.ErrorContains(t, err, "missing socket path")
+}
@@ -15,6 +15,8 @@ import (
 	"gopkg.in/yaml.v3"
 	"os"
 	"regexp"
+	"sort"
+	"time"
 )
 
 // defaultFile is the default filename for the environment configuration,
@@ -45,12 +47,51 @@ func buildEnv(flags flagValues) (*envite.Environment, error) {
 		envID = flags.envID.value
 	}
 
-	graph, err := buildComponentGraph(flags, envConfig, envID)
+	graph, options, err := buildComponentGraph(flags, envConfig, envID)
 	if err != nil {
 		return nil, fmt.Errorf("could not build component graph: %w", err)
 	}
 
-	return envite.NewEnvironment(envID, graph, envite.WithLogger(logger))
+	failurePolicy, err := envite.ParseFailurePolicy(flags.failurePolicy.value)
+	if err != nil {
+		return nil, err
+	}
+
+	rendering, err := terminalRendering(flags.color.value, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(
+		options,
+		envite.WithLogger(newLogger(rendering)),
+		envite.WithFailurePolicy(failurePolicy),
+		envite.WithOutputLimits(envite.OutputLimits{MaxLines: flags.outputMaxLines, MaxBytes: flags.outputMaxBytes}),
+	)
+	if flags.outputSpillFile.exist {
+		options = append(options, envite.WithOutputSpillFile(flags.outputSpillFile.value))
+	}
+	if flags.logDir.exist {
+		logFormat, err := envite.ParseLogFileFormat(flags.logFormat.value)
+		if err != nil {
+			return nil, err
+		}
+		logRendering := envite.OutputRenderingPlain
+		if flags.logColor.exist {
+			logRendering, err = envite.ParseOutputRendering(flags.logColor.value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		options = append(options, envite.WithLogFiles(envite.LogFiles{
+			Dir:        flags.logDir.value,
+			Format:     logFormat,
+			MaxSize:    flags.logMaxSize,
+			MaxBackups: flags.logMaxBackups,
+			Rendering:  logRendering,
+		}))
+	}
+	return envite.NewEnvironment(envID, graph, options...)
 }
 
 // environmentConfig represents the structure of the environment configuration file.
@@ -171,23 +212,177 @@ func extractComponentType(err error, data []byte) (string, error) {
 // buildComponentGraph constructs an envite.ComponentGraph from the environment configuration.
 // It iterates through each component layer, constructing components and adding them to the graph.
 // Components are built using the buildComponent function and are organized based on their dependencies.
-// Returns a fully constructed ComponentGraph or an error if any component fails to build.
-func buildComponentGraph(flags flagValues, envConfig environmentConfig, envID string) (*envite.ComponentGraph, error) {
+// By default, a component depends on all components in previous layers. A component that specifies
+// a depends_on list depends only on the components listed in it.
+// Returns a fully constructed ComponentGraph along with environment options derived from component settings,
+// or an error if any component fails to build.
+func buildComponentGraph(
+	flags flagValues,
+	envConfig environmentConfig,
+	envID string,
+) (*envite.ComponentGraph, []envite.Option, error) {
 	byID := make(map[string]envite.Component)
+	var previous []string
+	var options []envite.Option
 	graph := envite.NewComponentGraph()
 	for _, layer := range envConfig.Components {
-		components := make(map[string]envite.Component, len(layer))
-		for id, rawValue := range layer {
+		ids := make([]string, 0, len(layer))
+		for id := range layer {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			rawValue, settings, err := extractSettings(layer[id])
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not build component %s: %w", id, err)
+			}
+
 			component, err := buildComponent(rawValue, flags, envID, byID)
 			if err != nil {
-				return nil, fmt.Errorf("could not build component %s: %w", id, err)
+				return nil, nil, fmt.Errorf("could not build component %s: %w", id, err)
+			}
+
+			dependsOn := settings.DependsOn
+			if dependsOn == nil {
+				dependsOn = previous
 			}
-			components[id] = component
+			graph.AddComponent(id, component, dependsOn...)
 			byID[id] = component
+
+			if settings.Lifecycle != nil {
+				policy, err := settings.Lifecycle.policy()
+				if err != nil {
+					return nil, nil, fmt.Errorf("could not parse lifecycle of component %s: %w", id, err)
+				}
+				options = append(options, envite.WithLifecyclePolicy(id, policy))
+			}
+			if len(settings.Alerts) > 0 {
+				rules := make([]envite.AlertRule, 0, len(settings.Alerts))
+				for _, config := range settings.Alerts {
+					rule, err := config.rule()
+					if err != nil {
+						return nil, nil, fmt.Errorf("could not parse alerts of component %s: %w", id, err)
+					}
+					rules = append(rules, rule)
+				}
+				options = append(options, envite.WithAlertRules(id, rules...))
+			}
+		}
+		previous = append(previous, ids...)
+	}
+	return graph, options, nil
+}
+
+// settingsKeys are the component config keys used for ENVITE settings rather than the config of the component itself.
+var settingsKeys = []string{"depends_on", "lifecycle", "alerts"}
+
+// componentSettings holds ENVITE settings of a component, which are not part of the component config itself.
+type componentSettings struct {
+	// DependsOn explicitly lists the IDs of the components the component depends on.
+	// It is nil if not provided, in which case the component depends on all components in previous layers.
+	DependsOn []string `json:"depends_on"`
+
+	// Lifecycle sets timeouts and retries used when operating on the component.
+	Lifecycle *lifecycleConfig `json:"lifecycle"`
+
+	// Alerts lists rules raising alerts for lines of output of the component.
+	Alerts []alertConfig `json:"alerts"`
+}
+
+// lifecycleConfig is the CLI representation of envite.LifecyclePolicy, with durations such as "30s" or "2m".
+type lifecycleConfig struct {
+	StartTimeout string `json:"start_timeout"`
+	StopTimeout  string `json:"stop_timeout"`
+	Retries      int    `json:"retries"`
+	RetryBackoff string `json:"retry_backoff"`
+}
+
+// policy converts the lifecycleConfig into an envite.LifecyclePolicy.
+func (l lifecycleConfig) policy() (envite.LifecyclePolicy, error) {
+	result := envite.LifecyclePolicy{Retries: l.Retries}
+	durations := []struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{name: "start_timeout", value: l.StartTimeout, target: &result.StartTimeout},
+		{name: "stop_timeout", value: l.StopTimeout, target: &result.StopTimeout},
+		{name: "retry_backoff", value: l.RetryBackoff, target: &result.RetryBackoff},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		value, err := time.ParseDuration(d.value)
+		if err != nil {
+			return envite.LifecyclePolicy{}, fmt.Errorf("could not parse %s: %w", d.name, err)
 		}
-		graph.AddLayer(components)
+		*d.target = value
+	}
+	return result, nil
+}
+
+// alertConfig is the CLI representation of envite.AlertRule, with a regular expression string.
+type alertConfig struct {
+	Pattern  string `json:"pattern"`
+	Severity string `json:"severity"`
+}
+
+// rule converts the alertConfig into an envite.AlertRule.
+func (a alertConfig) rule() (envite.AlertRule, error) {
+	pattern, err := regexp.Compile(a.Pattern)
+	if err != nil {
+		return envite.AlertRule{}, fmt.Errorf("could not parse pattern: %w", err)
 	}
-	return graph, nil
+	severity, err := envite.ParseAlertSeverity(a.Severity)
+	if err != nil {
+		return envite.AlertRule{}, err
+	}
+	return envite.AlertRule{Pattern: pattern, Severity: severity}, nil
+}
+
+// extractSettings removes the ENVITE settings keys from a raw component config and returns the remaining config
+// along with the parsed settings.
+func extractSettings(rawValue any) (any, componentSettings, error) {
+	config, ok := rawValue.(map[string]any)
+	if !ok {
+		return rawValue, componentSettings{}, nil
+	}
+
+	result := make(map[string]any, len(config))
+	rawSettings := make(map[string]any)
+	for key, value := range config {
+		isSetting := false
+		for _, settingsKey := range settingsKeys {
+			if key == settingsKey {
+				isSetting = true
+				break
+			}
+		}
+		if isSetting {
+			rawSettings[key] = value
+		} else {
+			result[key] = value
+		}
+	}
+
+	var settings componentSettings
+	if len(rawSettings) == 0 {
+		return result, settings, nil
+	}
+
+	data, err := json.Marshal(rawSettings)
+	if err != nil {
+		return nil, settings, fmt.Errorf("could not marshal component settings: %w", err)
+	}
+
+	err = json.Unmarshal(data, &settings)
+	if err != nil {
+		return nil, settings, fmt.Errorf("could not parse component settings: %w", err)
+	}
+
+	return result, settings, nil
 }
 
 // ErrUnsupportedComponentType represents an error for component types that are not supported.
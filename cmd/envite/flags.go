@@ -17,8 +17,32 @@ type flagValues struct {
 	mode            envite.ExecutionMode // Execution mode determines how the application will run.
 	file            stringFlag           // File path to an environment YAML file.
 	port            stringFlag           // Port number for the Web UI in daemon mode.
+	host            stringFlag           // Host to bind the Web UI to in daemon mode.
+	token           stringFlag           // Token required by requests to the Web UI and API in daemon mode.
+	generateToken   bool                 // Whether to require a randomly generated token in daemon mode.
+	allowedOrigins  stringFlag           // Comma separated origins allowed to send browser requests in daemon mode.
+	tlsCert         stringFlag           // Path to a PEM encoded TLS certificate to serve HTTPS with in daemon mode.
+	tlsKey          stringFlag           // Path to the PEM encoded private key of the TLS certificate.
+	tlsSelfSigned   bool                 // Whether to serve HTTPS with a self-signed certificate in daemon mode.
+	socket          stringFlag           // Path to a Unix domain socket to listen on in daemon mode instead of a port.
+	socketMode      stringFlag           // Octal file permissions of the Unix domain socket.
+	exitPolicy      stringFlag           // What to do with the environment when the daemon is interrupted.
 	envID           stringFlag           // Environment ID to override the default provided in the environment file.
 	dockerNetworkID stringFlag           // Docker network identifier for environments with Docker components.
+	failurePolicy   stringFlag           // Policy for handling component failures while starting the environment.
+	outputMaxLines  int                  // Maximum number of output lines kept in memory, zero for no limit.
+	outputMaxBytes  int                  // Maximum size of output kept in memory in bytes, zero for no limit.
+	outputSpillFile stringFlag           // File to append output evicted from memory to.
+	logDir          stringFlag           // Directory to mirror output to as log files.
+	logFormat       stringFlag           // Format of the log files.
+	logMaxSize      int64                // Size in bytes at which log files are rotated, zero for no rotation.
+	logMaxBackups   int                  // Number of rotated log files to keep, zero to keep all.
+	logColor        stringFlag           // How colors are rendered in log files.
+	color           stringFlag           // Whether colors are rendered in terminal output.
+	verbosity       stringFlag           // How much component output is written to the terminal in start/stop modes.
+	show            stringFlag           // Comma separated IDs of the components whose output is written to the terminal.
+	tailOnFailure   int                  // Number of last output lines of a failed component to write, zero for none.
+	remote          []string             // Command and arguments sent to a running daemon, see isRemoteCommand.
 }
 
 // parseFlags parses command-line arguments into flagValues.
@@ -30,15 +54,63 @@ func parseFlags() flagValues {
 
 	flag.Var(&f.file, "file", "Path to an environment yaml file (default: `envite.yml`)")
 	flag.Var(&f.port, "port", "Web UI port to be used if mode is daemon (default: `4005`)")
+	flag.Var(&f.host, "host", "Host to bind the Web UI to if mode is daemon. "+
+		"An empty host binds to all network interfaces (default: `localhost`)")
+	flag.Var(&f.token, "token", "Token required by Web UI and API requests if mode is daemon, "+
+		"either as a bearer token, a token header, or a token query parameter")
+	flag.BoolVar(&f.generateToken, "generate-token", false, "Require a randomly generated token "+
+		"if mode is daemon. The token is printed at startup as part of the Web UI link")
+	flag.Var(&f.allowedOrigins, "allowed-origins", "Comma separated origins, such as http://localhost:3000, "+
+		"allowed to send browser requests to the API if mode is daemon, in addition to the Web UI itself. "+
+		"Use * to allow any origin")
+	flag.Var(&f.tlsCert, "tls-cert", "Path to a PEM encoded certificate to serve HTTPS with if mode is daemon. "+
+		"Requires -tls-key")
+	flag.Var(&f.tlsKey, "tls-key", "Path to the PEM encoded private key of -tls-cert")
+	flag.BoolVar(&f.tlsSelfSigned, "tls-self-signed", false, "Serve HTTPS with a self-signed certificate "+
+		"generated at startup if mode is daemon")
+	flag.Var(&f.socket, "socket", "Path to a Unix domain socket to listen on instead of a port if mode is daemon")
+	flag.Var(&f.socketMode, "socket-mode", "File permissions of -socket, in octal (default: `0600`)")
+	flag.Var(&f.exitPolicy, "on-exit", "What to do with the components when the daemon receives an interrupt or "+
+		"termination signal. One of `leave`, `stop` or `cleanup`. A second signal quits immediately (default: `leave`)")
 	flag.Var(&f.envID, "id", "Override the environment ID provided by the environment yaml")
 	flag.Var(&f.dockerNetworkID, "network", "Docker network identifier to be used. "+
 		"Used only if docker components exist in the environment file. If not provided, ENVITE will create "+
 		"a dedicated open docker network.")
+	flag.Var(&f.failurePolicy, "on-failure", "Policy for handling component failures while starting components. "+
+		"One of `fail_fast`, `continue` or `rollback` (default: `fail_fast`)")
+	flag.IntVar(&f.outputMaxLines, "output-max-lines", 0, "Maximum number of output lines to keep in memory. "+
+		"Older lines are evicted (default: no limit)")
+	flag.IntVar(&f.outputMaxBytes, "output-max-bytes", 0, "Maximum size of output in bytes to keep in memory. "+
+		"Older lines are evicted (default: no limit)")
+	flag.Var(&f.outputSpillFile, "output-spill-file", "Path to a file to append output evicted from memory to")
+	flag.Var(&f.logDir, "log-dir", "Directory to write the output of all components to, as a combined log file "+
+		"and a log file per component")
+	flag.Var(&f.logFormat, "log-format", "Format of log files written to -log-dir. "+
+		"One of `plain` or `ndjson` (default: `plain`)")
+	flag.Int64Var(&f.logMaxSize, "log-max-size", 10*1024*1024, "Size in bytes at which a log file is rotated. "+
+		"Zero disables rotation")
+	flag.IntVar(&f.logMaxBackups, "log-max-backups", 5, "Number of rotated files to keep for each log file. "+
+		"Zero keeps all rotated files")
+	flag.Var(&f.logColor, "log-color", "How colors are rendered in log files. "+
+		"One of `plain`, `ansi` or `html` (default: `plain`)")
+	flag.Var(&f.color, "color", "Whether to color terminal output. One of `auto`, `always` or `never`. "+
+		"auto colors output only if it is a terminal (default: `auto`)")
+	flag.Var(&f.verbosity, "verbosity", "How much component output to print while start, stop or restart "+
+		"modes run. One of `quiet`, `normal` or `verbose`. normal prints info level and above (default: `normal`)")
+	flag.Var(&f.show, "show", "Comma separated IDs of the components whose output is printed while start, stop "+
+		"or restart modes run (default: all components)")
+	flag.IntVar(&f.tailOnFailure, "tail-on-failure", 0, "Number of last output lines of each failed component "+
+		"to print when start, stop or restart modes fail. Zero prints none")
 
 	flag.Parse()
+	if isRemoteCommand(flag.Args()) {
+		f.remote = flag.Args()
+		return f
+	}
+
 	mode, err := envite.ParseExecutionMode(flag.Arg(0))
 	if err != nil {
-		fmt.Printf("%s. %s\n", err.Error(), envite.DescribeAvailableModes())
+		fmt.Printf("%s. %s\n%s", err.Error(), envite.DescribeAvailableModes(), describeRemoteCommands())
 		os.Exit(1)
 	}
 
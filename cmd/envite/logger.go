@@ -10,17 +10,20 @@ import (
 	"os"
 )
 
-// logger provides a simple logging function for the application, outputting messages to the standard output.
+// newLogger returns a simple logging function for the application, outputting messages to the standard output.
 // It prefixes log messages with their log level, except for info level messages, to make it easier to distinguish
 // the severity of log messages. Fatal log messages cause the application to exit with a status code of 1.
+// Colors in messages are rendered according to rendering.
 // This logger is intended for the CLI.
-func logger(level envite.LogLevel, message string) {
-	var levelPrefix string
-	if level != envite.LogLevelInfo {
-		levelPrefix = fmt.Sprintf("[%s] ", level)
-		fmt.Println(levelPrefix + message)
-	}
-	if level == envite.LogLevelFatal {
-		os.Exit(1)
+func newLogger(rendering envite.OutputRendering) envite.Logger {
+	return func(level envite.LogLevel, message string) {
+		var levelPrefix string
+		if level != envite.LogLevelInfo {
+			levelPrefix = fmt.Sprintf("[%s] ", level)
+			fmt.Println(levelPrefix + envite.RenderANSI(message, rendering))
+		}
+		if level == envite.LogLevelFatal {
+			os.Exit(1)
+		}
 	}
 }
@@ -5,15 +5,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/perimeterx/envite"
 	"os"
 )
 
 // main is the entry point of the CLI.
-// It executes the main application logic and exits with status code 1 in case of an error.
+// It executes the main application logic and exits with status code 1 in case of an error,
+// or with the exit code of a command executed in a component.
 func main() {
 	err := exec()
+	var exitErr exitCodeError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
+	}
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
@@ -23,14 +29,28 @@ func main() {
 // exec orchestrates the execution flow of the application.
 // It parses command-line flags, initializes the environment,
 // and starts the server based on the provided configuration.
+// Commands for a running daemon are sent to its API instead, see runRemote.
 // Returns an error if any step in the process fails.
 func exec() error {
 	flags := parseFlags()
+	if flags.remote != nil {
+		return runRemote(flags)
+	}
+
+	streaming, err := outputStreaming(flags)
+	if err != nil {
+		return err
+	}
+
 	env, err := buildEnv(flags)
 	if err != nil {
 		return err
 	}
 
-	server := buildServer(env, flags)
-	return envite.Execute(server, flags.mode)
+	server, err := buildServer(env, flags)
+	if err != nil {
+		return err
+	}
+
+	return envite.Execute(server, flags.mode, envite.WithOutputStreaming(streaming))
 }
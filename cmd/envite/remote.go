@@ -0,0 +1,473 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/perimeterx/envite"
+	"github.com/perimeterx/envite/client"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+)
+
+const (
+	// defaultAddress is the address of the daemon remote commands are sent to, unless provided otherwise.
+	defaultAddress = "http://localhost:" + defaultPort
+
+	// addressEnv and tokenEnv are environment variables that set the default address and token of remote commands.
+	addressEnv = "ENVITE_ADDRESS"
+	tokenEnv   = "ENVITE_TOKEN"
+)
+
+const (
+	commandStatus = "status"
+	commandStart  = "start"
+	commandStop   = "stop"
+	commandApply  = "apply"
+	commandLogs   = "logs"
+	commandExec   = "exec"
+)
+
+// isRemoteCommand reports whether args, following the global flags, are a command sent to a running daemon rather
+// than an execution mode. start and stop are execution modes unless followed by more arguments, such as a component ID.
+func isRemoteCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case commandStatus, commandApply, commandLogs, commandExec:
+		return true
+	case commandStart, commandStop:
+		return len(args) > 1
+	}
+	return false
+}
+
+// describeRemoteCommands returns a string describing all commands sent to a running daemon.
+func describeRemoteCommands() string {
+	return "commands for a running daemon, see -h of each command for its flags:\n" +
+		"status - print the status of all components\n" +
+		"start <id> - start a component\n" +
+		"stop <id> - stop a component\n" +
+		"apply <id>... - start the given components and stop all others\n" +
+		"logs [-f] [<id>...] - print the output of components, and follow new output with -f\n" +
+		"exec <id> -- <command>... - execute a command in a component\n"
+}
+
+// remoteFlags holds the flags of a command sent to a running daemon.
+type remoteFlags struct {
+	address          string // Address of the daemon.
+	socket           string // Path to the Unix domain socket of the daemon, instead of the address.
+	token            string // Token required by the daemon.
+	insecure         bool   // Whether to skip verifying the TLS certificate of the daemon.
+	json             bool   // Whether to print JSON instead of human-readable output.
+	withDependencies bool   // Whether start also starts the dependencies of the component.
+	cascade          bool   // Whether start restarts running components that depend on the component.
+	withDependents   bool   // Whether stop also stops the components that depend on the component.
+	follow           bool   // Whether logs follows new output.
+	lines            int    // Number of last lines logs prints, zero for all lines kept in memory.
+}
+
+// remote runs commands against a running daemon and prints their results.
+type remote struct {
+	client    *client.Client
+	out       io.Writer
+	json      bool
+	rendering envite.OutputRendering
+}
+
+// runRemote runs the command sent to a running daemon held by flags, see isRemoteCommand.
+func runRemote(flags flagValues) error {
+	name, args := flags.remote[0], flags.remote[1:]
+	f := remoteFlags{}
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	set.StringVar(&f.address, "address", envOrDefault(addressEnv, defaultAddress), "Address of the daemon, "+
+		"such as the Web UI link it printed at startup. Defaults to $"+addressEnv+" if set")
+	set.StringVar(&f.socket, "socket", "", "Path to the Unix domain socket the daemon listens on, instead of -address")
+	set.StringVar(&f.token, "token", "", "Token required by the daemon. Defaults to $"+tokenEnv+" if set")
+	set.BoolVar(&f.insecure, "insecure", false, "Skip verifying the TLS certificate of an https address, "+
+		"such as a self-signed certificate")
+	set.BoolVar(&f.json, "json", false, "Print JSON instead of a human-readable table")
+	switch name {
+	case commandStart:
+		set.BoolVar(&f.withDependencies, "with-dependencies", false, "Start the dependencies of the component first")
+		set.BoolVar(&f.cascade, "cascade", false, "Restart running components that depend on the component")
+	case commandStop:
+		set.BoolVar(&f.withDependents, "with-dependents", false, "Stop the components depending on the component first")
+	case commandLogs:
+		set.BoolVar(&f.follow, "f", false, "Follow new output until interrupted")
+		set.IntVar(&f.lines, "n", 0, "Number of last lines to print. Zero prints all lines kept in memory")
+	}
+	stopAfter := 0
+	if name == commandExec {
+		// the command executed in the component follows its ID, and may have flags of its own
+		stopAfter = 1
+	}
+	args, err := parseInterspersed(set, args, stopAfter)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err := f.client()
+	if err != nil {
+		return err
+	}
+	rendering, err := terminalRendering(flags.color.value, os.Stdout)
+	if err != nil {
+		return err
+	}
+	r := remote{client: c, out: os.Stdout, json: f.json, rendering: rendering}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	switch name {
+	case commandStatus:
+		return r.status(ctx)
+	case commandStart:
+		if len(args) != 1 {
+			return errors.New("start requires a single component ID")
+		}
+		return r.start(ctx, envite.PostStartRequest{
+			ComponentID:      args[0],
+			WithDependencies: f.withDependencies,
+			Cascade:          f.cascade,
+		})
+	case commandStop:
+		if len(args) != 1 {
+			return errors.New("stop requires a single component ID")
+		}
+		return r.stop(ctx, envite.PostStopRequest{ComponentID: args[0], WithDependents: f.withDependents})
+	case commandApply:
+		if len(args) == 0 {
+			return errors.New("apply requires the IDs of the components to enable")
+		}
+		return r.apply(ctx, args)
+	case commandLogs:
+		return r.logs(ctx, client.OutputQuery{Components: args, LastLines: f.lines}, f.follow)
+	case commandExec:
+		if len(args) < 2 {
+			return errors.New("exec requires a component ID and a command")
+		}
+		return r.exec(ctx, envite.PostExecRequest{ComponentID: args[0], Command: args[1:]})
+	}
+	return fmt.Errorf("unknown command %s", name)
+}
+
+// parseInterspersed parses the flags of set that come before, between or after positional arguments, unlike
+// set.Parse which stops at the first positional argument, and returns the positional arguments.
+// Arguments following "--" are never parsed as flags. If stopAfter is positive, the arguments following the first
+// stopAfter positional arguments are not parsed as flags either.
+func parseInterspersed(set *flag.FlagSet, args []string, stopAfter int) ([]string, error) {
+	var positional []string
+	for len(args) > 0 {
+		if stopAfter > 0 && len(positional) == stopAfter {
+			if args[0] == "--" {
+				args = args[1:]
+			}
+			return append(positional, args...), nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		if len(args[0]) < 2 || args[0][0] != '-' {
+			positional = append(positional, args[0])
+			args = args[1:]
+			continue
+		}
+
+		err := set.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		rest := set.Args()
+		if consumed := args[:len(args)-len(rest)]; consumed[len(consumed)-1] == "--" {
+			// set.Parse drops the "--" it stops at
+			return append(positional, rest...), nil
+		}
+		args = rest
+	}
+	return positional, nil
+}
+
+// client creates a client for the daemon set by the flags.
+func (f remoteFlags) client() (*client.Client, error) {
+	address := f.address
+	if f.socket != "" {
+		address = "unix://" + f.socket
+	}
+
+	// the token is not a flag default, so help output never prints it
+	token := envOrDefault(tokenEnv, "")
+	if f.token != "" {
+		token = f.token
+	}
+	var options []client.Option
+	if token != "" {
+		options = append(options, client.WithToken(token))
+	}
+	if f.insecure {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		options = append(options, client.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+	return client.New(address, options...)
+}
+
+// status prints the status of all components.
+func (r remote) status(ctx context.Context) error {
+	status, err := r.client.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if r.json {
+		return r.printJSON(status)
+	}
+
+	tw := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	_, err = fmt.Fprintln(tw, "COMPONENT\tTYPE\tSTATUS\tALERTS\tERROR")
+	if err != nil {
+		return err
+	}
+	for _, layer := range status.Components {
+		for _, c := range layer {
+			alerts := "-"
+			if c.Alerts != nil {
+				alerts = fmt.Sprintf("%d (%s)", c.Alerts.Count, c.Alerts.Severity)
+			}
+			failure := "-"
+			if c.Error != "" {
+				failure = c.Error
+			} else if c.FailureReason != "" {
+				failure = string(c.FailureReason)
+			}
+			_, err = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.ID, c.Type, c.Status, alerts, failure)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if status.Operation != nil {
+		operation := string(status.Operation.Operation)
+		if status.Operation.ComponentID != "" {
+			operation += " of " + status.Operation.ComponentID
+		}
+		_, err = fmt.Fprintf(r.out, "\noperation %s in progress since %s\n", operation,
+			status.Operation.StartedAt.Local().Format("15:04:05"))
+	}
+	return err
+}
+
+// start starts a component and prints the components that were started.
+func (r remote) start(ctx context.Context, request envite.PostStartRequest) error {
+	response, err := r.client.StartComponent(ctx, request)
+	if err != nil {
+		return err
+	}
+	if r.json {
+		return r.printJSON(response)
+	}
+	if len(response.Started) == 0 {
+		_, err = fmt.Fprintf(r.out, "%s is already running\n", request.ComponentID)
+		return err
+	}
+	return r.printResults(response.Started, "started")
+}
+
+// stop stops a component and prints the components that were stopped.
+func (r remote) stop(ctx context.Context, request envite.PostStopRequest) error {
+	response, err := r.client.StopComponent(ctx, request)
+	if err != nil {
+		return err
+	}
+	if r.json {
+		return r.printJSON(response)
+	}
+	return r.printResults(response.Stopped, "stopped")
+}
+
+// apply enables the given components and prints the resulting status, or the outcome of each component if any of
+// them failed.
+func (r remote) apply(ctx context.Context, ids []string) error {
+	err := r.client.Apply(ctx, envite.PostApplyRequest{EnabledComponentIDs: ids})
+	var errResponse client.ErrResponse
+	if !r.json && errors.As(err, &errResponse) && len(errResponse.Details) > 0 {
+		var applyErr envite.ApplyError
+		if json.Unmarshal(errResponse.Details, &applyErr) == nil && len(applyErr.Components) > 0 {
+			printErr := r.printOutcomes(applyErr.Components)
+			if printErr != nil {
+				return printErr
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return r.status(ctx)
+}
+
+// logs prints the output of components matching query, and follows new output until ctx is done if follow is set.
+func (r remote) logs(ctx context.Context, query client.OutputQuery, follow bool) error {
+	if !follow {
+		matches, err := r.client.SearchOutput(ctx, query, 0)
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			err = r.printRecord(match.Record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	stream, err := r.client.Output(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stream.Close()
+	}()
+	for {
+		record, err := stream.Next()
+		if ctx.Err() != nil || errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = r.printRecord(record)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// exec executes a command in a component and prints the output it wrote while the command ran.
+// It returns an exitCodeError if the command exits with a non-zero code.
+func (r remote) exec(ctx context.Context, request envite.PostExecRequest) error {
+	response, err := r.client.Exec(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	if r.json {
+		err = r.printJSON(response)
+	} else {
+		for _, record := range response.Output {
+			_, err = fmt.Fprintln(r.out, record.Render(r.rendering))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if response.ExitCode != 0 {
+		return exitCodeError{code: response.ExitCode}
+	}
+	return nil
+}
+
+// printResults prints a table of the components an operation acted on, with the given result.
+func (r remote) printResults(ids []string, result string) error {
+	tw := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	_, err := fmt.Fprintln(tw, "COMPONENT\tRESULT")
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		_, err = fmt.Fprintf(tw, "%s\t%s\n", id, result)
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// printOutcomes prints a table of the outcome of each component of a failed operation.
+func (r remote) printOutcomes(outcomes []envite.ComponentOutcome) error {
+	tw := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	_, err := fmt.Fprintln(tw, "COMPONENT\tACTION\tRESULT\tERROR")
+	if err != nil {
+		return err
+	}
+	for _, o := range outcomes {
+		failure := o.Error
+		if failure == "" {
+			failure = "-"
+		}
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", o.ID, o.Action, o.Result, failure)
+		if err != nil {
+			return err
+		}
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(r.out)
+	return err
+}
+
+// printRecord prints a line of output prefixed with the ID of its component, or as a JSON line.
+func (r remote) printRecord(record envite.OutputRecord) error {
+	if r.json {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(r.out, string(data))
+		return err
+	}
+	_, err := fmt.Fprintf(r.out, "%s | %s\n", record.ComponentID, record.Render(r.rendering))
+	return err
+}
+
+// printJSON prints v as indented JSON.
+func (r remote) printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(r.out, string(data))
+	return err
+}
+
+// envOrDefault returns the value of the environment variable key, or defaultValue if it is not set.
+func envOrDefault(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// exitCodeError makes the CLI exit with code without printing an error, such as a failed command executed
+// in a component.
+type exitCodeError struct {
+	code int
+}
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", e.code)
+}
@@ -5,31 +5,78 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"github.com/perimeterx/envite"
+	"os"
+	"strconv"
+	"strings"
 )
 
 // defaultPort is the default port used to serve the UI in daemon mode unless
 // explicitly provided otherwise via CLI flags.
 const defaultPort = "4005"
 
-// buildServer initializes a new server instance for the ENVITE environment if the execution mode is set to daemon.
-// This function checks if the necessary flags for server operation are provided and returns an error if required
-// information is missing.
+// buildServer initializes a new server instance for the ENVITE environment.
+// The server is used to execute all modes, but only serves requests in daemon mode.
 //
 // Returns a pointer to an initialized envite.Server instance ready to handle requests based on the
-// provided environment configuration. This return value is nil if the execution mode is not
-// set to daemon, indicating that a server instance is not required
+// provided environment configuration.
 //
-// If a port flag is not provided, defaultPort is used.
-func buildServer(env *envite.Environment, flags flagValues) *envite.Server {
-	if flags.mode != envite.ExecutionModeDaemon {
-		return nil
-	}
-
+// If a port flag is not provided, defaultPort is used. Unless flags set otherwise, the server serves plain HTTP
+// on localhost and does not require a token.
+// Returns an error if the TLS, socket or exit policy flags are invalid.
+func buildServer(env *envite.Environment, flags flagValues) (*envite.Server, error) {
 	port := defaultPort
 	if flags.port.exist {
 		port = flags.port.value
 	}
 
-	return envite.NewServer(port, env)
+	var options []envite.ServerOption
+	if flags.host.exist {
+		options = append(options, envite.WithHost(flags.host.value))
+	}
+	if flags.token.exist {
+		options = append(options, envite.WithAuthToken(flags.token.value))
+	} else if flags.generateToken {
+		options = append(options, envite.WithGeneratedAuthToken())
+	}
+	if flags.allowedOrigins.exist {
+		var origins []string
+		for _, o := range strings.Split(flags.allowedOrigins.value, ",") {
+			if o = strings.TrimSpace(o); o != "" {
+				origins = append(origins, o)
+			}
+		}
+		options = append(options, envite.WithAllowedOrigins(origins...))
+	}
+
+	if flags.tlsCert.exist != flags.tlsKey.exist {
+		return nil, errors.New("-tls-cert and -tls-key must be provided together")
+	}
+	if flags.tlsCert.exist {
+		options = append(options, envite.WithTLS(flags.tlsCert.value, flags.tlsKey.value))
+	} else if flags.tlsSelfSigned {
+		options = append(options, envite.WithSelfSignedTLS())
+	}
+	if flags.socket.exist {
+		var mode uint64
+		if flags.socketMode.exist {
+			var err error
+			mode, err = strconv.ParseUint(flags.socketMode.value, 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid socket mode %s: %w", flags.socketMode.value, err)
+			}
+		}
+		options = append(options, envite.WithUnixSocket(flags.socket.value, os.FileMode(mode)))
+	}
+	if flags.exitPolicy.exist {
+		policy, err := envite.ParseExitPolicy(flags.exitPolicy.value)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, envite.WithExitPolicy(policy))
+	}
+
+	return envite.NewServer(port, env, options...), nil
 }
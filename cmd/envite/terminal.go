@@ -0,0 +1,95 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/perimeterx/envite"
+	"os"
+	"strings"
+)
+
+const (
+	// colorAuto renders colors only if the output is a terminal. This is the default.
+	colorAuto = "auto"
+
+	// colorAlways always renders colors using ANSI escape codes.
+	colorAlways = "always"
+
+	// colorNever never renders colors.
+	colorNever = "never"
+)
+
+const (
+	// verbosityQuiet prints no component output, except for the output of failed components, see -tail-on-failure.
+	verbosityQuiet = "quiet"
+
+	// verbosityNormal prints component output at info level and above. This is the default.
+	verbosityNormal = "normal"
+
+	// verbosityVerbose prints all component output.
+	verbosityVerbose = "verbose"
+)
+
+// outputStreaming returns the configuration for printing component output to stdout while operations run,
+// according to the flags.
+func outputStreaming(flags flagValues) (envite.OutputStreaming, error) {
+	rendering, err := terminalRendering(flags.color.value, os.Stdout)
+	if err != nil {
+		return envite.OutputStreaming{}, err
+	}
+
+	result := envite.OutputStreaming{Writer: os.Stdout, Rendering: rendering, TailOnFailure: flags.tailOnFailure}
+	switch flags.verbosity.value {
+	case verbosityNormal, "":
+		result.MinLevel = envite.LogLevelInfo
+	case verbosityVerbose:
+		result.MinLevel = envite.LogLevelTrace
+	case verbosityQuiet:
+		result.Quiet = true
+	default:
+		return envite.OutputStreaming{}, fmt.Errorf(
+			"invalid verbosity %s, must be one of %s, %s or %s",
+			flags.verbosity.value,
+			verbosityQuiet,
+			verbosityNormal,
+			verbosityVerbose,
+		)
+	}
+	if flags.show.exist {
+		for _, id := range strings.Split(flags.show.value, ",") {
+			id = strings.TrimSpace(id)
+			if id != "" {
+				result.Components = append(result.Components, id)
+			}
+		}
+	}
+	return result, nil
+}
+
+// terminalRendering returns how colored text is rendered when written to out, according to the color flag.
+func terminalRendering(color string, out *os.File) (envite.OutputRendering, error) {
+	switch color {
+	case colorAuto, "":
+		if isTerminal(out) {
+			return envite.OutputRenderingANSI, nil
+		}
+		return envite.OutputRenderingPlain, nil
+	case colorAlways:
+		return envite.OutputRenderingANSI, nil
+	case colorNever:
+		return envite.OutputRenderingPlain, nil
+	}
+	return "", fmt.Errorf("invalid color %s, must be one of %s, %s or %s", color, colorAuto, colorAlways, colorNever)
+}
+
+// isTerminal reports whether f is a terminal, rather than a file or a pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -41,6 +41,22 @@ type Component interface {
 	Config() any
 }
 
+// Restarter is an optional interface a Component can implement to be restarted in place, e.g. keeping its state.
+// Components that do not implement it are restarted by stopping, preparing and then starting them.
+type Restarter interface {
+	// Restart restarts the component's operation. It is only called while the component is running,
+	// and should return once the component is operational again, like Start.
+	Restart(ctx context.Context) error
+}
+
+// Executor is an optional interface a Component can implement to execute commands, such as a command in the container
+// of a docker component. See Environment.ExecComponent.
+type Executor interface {
+	// Exec executes cmd in the component and returns its exit code. The output of the command should be written
+	// to the output of the component.
+	Exec(ctx context.Context, cmd []string) (int, error)
+}
+
 // ComponentStatus represents the operational status of a component within the environment.
 type ComponentStatus string
 
@@ -60,3 +76,17 @@ const (
 	// ComponentStatusFinished indicates that the component has completed its operation successfully and has stopped running.
 	ComponentStatusFinished ComponentStatus = "finished"
 )
+
+// ComponentFailureReason represents the reason a component is reported as ComponentStatusFailed by the Environment.
+type ComponentFailureReason string
+
+const (
+	// ComponentFailureReasonError indicates the latest action on the component returned an error.
+	ComponentFailureReasonError ComponentFailureReason = "error"
+
+	// ComponentFailureReasonStartTimeout indicates the component did not start within its configured start timeout.
+	ComponentFailureReasonStartTimeout ComponentFailureReason = "start_timeout"
+
+	// ComponentFailureReasonStopTimeout indicates the component did not stop within its configured stop timeout.
+	ComponentFailureReasonStopTimeout ComponentFailureReason = "stop_timeout"
+)
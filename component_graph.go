@@ -4,20 +4,36 @@
 
 package envite
 
-// ComponentGraph represents a graph of components organized in layers.
-// Each layer can contain one or more components that can depend on components from the previous layers.
-// A layer is represented as a map, mapping from component ID to a component. Layer components are assumed
-// to not depend on each other and can be operated on concurrently.
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ComponentGraph represents a directed acyclic graph of components and the dependencies between them.
+// Each component is identified by a unique ID and may depend on any number of other components in the graph.
+// A component is only operated on once all of its dependencies are ready, while components that do not
+// depend on each other are operated on concurrently.
+//
+// Components can be added either with explicit dependencies using AddComponent and DependsOn,
+// or in layers using AddLayer, where each layer depends on all components added before it.
 //
 // This structure is useful for initializing, starting, and stopping components in the correct order,
 // ensuring that dependencies are correctly managed.
 type ComponentGraph struct {
-	components []map[string]Component
+	components   []graphComponent
+	dependencies map[string][]string
+}
+
+// graphComponent is a single component added to a ComponentGraph, in the order it was added.
+type graphComponent struct {
+	id        string
+	component Component
 }
 
 // NewComponentGraph creates a new instance of ComponentGraph.
 // It initializes an empty graph with no components and returns a pointer to it.
-// This function is the starting point for building a graph of components by adding layers.
+// This function is the starting point for building a graph of components by adding layers or components.
 //
 // Example:
 //
@@ -32,13 +48,13 @@ type ComponentGraph struct {
 //
 // This example creates a new component graph and adds two layers to it.
 func NewComponentGraph() *ComponentGraph {
-	return &ComponentGraph{}
+	return &ComponentGraph{dependencies: make(map[string][]string)}
 }
 
 // AddLayer adds a new layer of components to the ComponentGraph.
 // Each call to AddLayer represents a new level in the graph, with the given components being added as a single layer.
 // Components within the same layer are considered to have no dependencies on each other,
-// but depend on components from all previous layers.
+// but depend on all components that were added to the graph before the layer.
 //
 // Parameters:
 //
@@ -57,8 +73,205 @@ func NewComponentGraph() *ComponentGraph {
 //
 // This example creates a new component graph and adds two layers to it.
 func (c *ComponentGraph) AddLayer(components map[string]Component) *ComponentGraph {
-	if len(components) > 0 {
-		c.components = append(c.components, components)
+	previous := make([]string, 0, len(c.components))
+	for _, gc := range c.components {
+		previous = append(previous, gc.id)
+	}
+
+	ids := make([]string, 0, len(components))
+	for id := range components {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		c.AddComponent(id, components[id], previous...)
+	}
+	return c
+}
+
+// AddComponent adds a single component to the ComponentGraph, optionally declaring the IDs of the components
+// it depends on. The component is started only after all of its dependencies are ready, and stopped
+// before any of them are stopped. Dependencies may refer to components that are added to the graph later on.
+//
+// Example:
+//
+//	graph := NewComponentGraph().
+//		AddComponent("kafka", kafka).
+//		AddComponent("redis", redis).
+//		AddComponent("redis-seed", redisSeed, "redis").
+//		AddComponent("service", service, "kafka", "redis-seed")
+//
+// In this example "redis-seed" starts as soon as "redis" is ready, regardless of how long "kafka" takes to start.
+func (c *ComponentGraph) AddComponent(id string, component Component, dependsOn ...string) *ComponentGraph {
+	c.components = append(c.components, graphComponent{id: id, component: component})
+	return c.DependsOn(id, dependsOn...)
+}
+
+// DependsOn declares that the component identified by componentID depends on the components identified
+// by dependencyIDs. Dependencies are validated, including cycle detection, when the graph is used to
+// create an Environment.
+func (c *ComponentGraph) DependsOn(componentID string, dependencyIDs ...string) *ComponentGraph {
+	if c.dependencies == nil {
+		c.dependencies = make(map[string][]string)
+	}
+	for _, dependencyID := range dependencyIDs {
+		exists := false
+		for _, existing := range c.dependencies[componentID] {
+			if existing == dependencyID {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			c.dependencies[componentID] = append(c.dependencies[componentID], dependencyID)
+		}
 	}
 	return c
 }
+
+// dependencyGraph is the validated form of a ComponentGraph, used by the Environment
+// to schedule operations on components according to their dependencies.
+type dependencyGraph struct {
+	// ids holds all component IDs, sorted lexicographically.
+	ids []string
+
+	// dependencies maps a component ID to the IDs of the components it directly depends on.
+	dependencies map[string][]string
+
+	// dependents maps a component ID to the IDs of the components that directly depend on it.
+	dependents map[string][]string
+
+	// layers groups components by their depth in the graph. Components with no dependencies are
+	// in the first layer, and any other component is in the layer following its deepest dependency.
+	layers [][]string
+}
+
+// newDependencyGraph validates the dependencies of the given components, making sure all of them exist
+// and that no cycles are formed, and returns the resulting dependencyGraph.
+func newDependencyGraph(components map[string]Component, dependencies map[string][]string) (*dependencyGraph, error) {
+	g := &dependencyGraph{
+		ids:          make([]string, 0, len(components)),
+		dependencies: make(map[string][]string, len(components)),
+		dependents:   make(map[string][]string, len(components)),
+	}
+	for id := range components {
+		g.ids = append(g.ids, id)
+	}
+	sort.Strings(g.ids)
+
+	for id, dependencyIDs := range dependencies {
+		if _, ok := components[id]; !ok {
+			return nil, ErrInvalidComponentID{id: id, msg: "dependencies declared for a component that does not exist"}
+		}
+		for _, dependencyID := range dependencyIDs {
+			if _, ok := components[dependencyID]; !ok {
+				return nil, ErrInvalidComponentID{
+					id:  dependencyID,
+					msg: fmt.Sprintf("component %s depends on a component that does not exist", id),
+				}
+			}
+		}
+	}
+
+	for _, id := range g.ids {
+		dependencyIDs := append([]string(nil), dependencies[id]...)
+		sort.Strings(dependencyIDs)
+		g.dependencies[id] = dependencyIDs
+		for _, dependencyID := range dependencyIDs {
+			g.dependents[dependencyID] = append(g.dependents[dependencyID], id)
+		}
+	}
+
+	depths := make(map[string]int, len(g.ids))
+	for _, id := range g.ids {
+		_, err := g.depth(id, depths, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, id := range g.ids {
+		depth := depths[id]
+		for len(g.layers) <= depth {
+			g.layers = append(g.layers, nil)
+		}
+		g.layers[depth] = append(g.layers[depth], id)
+	}
+
+	return g, nil
+}
+
+// transitive returns the IDs of all components reachable from the given component by following edges,
+// which is either the dependencies or the dependents mapping of the graph. The component itself is not included.
+func (g *dependencyGraph) transitive(id string, edges map[string][]string) map[string]struct{} {
+	result := make(map[string]struct{})
+	pending := append([]string(nil), edges[id]...)
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if _, ok := result[current]; ok {
+			continue
+		}
+		result[current] = struct{}{}
+		pending = append(pending, edges[current]...)
+	}
+	return result
+}
+
+// sorted returns the given component IDs ordered by their dependencies, so that each component
+// appears after all of its dependencies. If reverse is true, each component appears before all of its dependencies.
+func (g *dependencyGraph) sorted(ids map[string]struct{}, reverse bool) []string {
+	result := make([]string, 0, len(ids))
+	for _, layer := range g.layers {
+		for _, id := range layer {
+			if _, ok := ids[id]; ok {
+				result = append(result, id)
+			}
+		}
+	}
+	if reverse {
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+	return result
+}
+
+// depth calculates the depth of a component in the graph, memoizing results in depths.
+// path holds the components currently being visited and is used to detect dependency cycles.
+func (g *dependencyGraph) depth(id string, depths map[string]int, path []string) (int, error) {
+	if depth, ok := depths[id]; ok {
+		return depth, nil
+	}
+
+	for i, visited := range path {
+		if visited == id {
+			return 0, ErrDependencyCycle{path: append(append([]string(nil), path[i:]...), id)}
+		}
+	}
+
+	path = append(path, id)
+	result := 0
+	for _, dependencyID := range g.dependencies[id] {
+		depth, err := g.depth(dependencyID, depths, path)
+		if err != nil {
+			return 0, err
+		}
+		if depth+1 > result {
+			result = depth + 1
+		}
+	}
+
+	depths[id] = result
+	return result, nil
+}
+
+// ErrDependencyCycle represents an error when the dependencies between components form a cycle.
+type ErrDependencyCycle struct {
+	path []string
+}
+
+func (e ErrDependencyCycle) Error() string {
+	return fmt.Sprintf("dependency cycle detected: %s", strings.Join(e.path, " -> "))
+}
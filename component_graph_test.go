@@ -0,0 +1,121 @@
+package envite
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestComponentGraphLayersTranslation(t *testing.T) {
+	graph := NewComponentGraph().
+		AddLayer(map[string]Component{"a": &mockComponent{}, "b": &mockComponent{}}).
+		AddLayer(map[string]Component{"c": &mockComponent{}}).
+		AddLayer(map[string]Component{"d": &mockComponent{}, "e": &mockComponent{}})
+
+	env, err := NewEnvironment("test-env", graph)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}, {"d", "e"}}, env.graph.layers)
+	assert.Equal(t, []string{"a", "b", "c"}, env.graph.dependencies["d"])
+	assert.Equal(t, []string{"c", "d", "e"}, env.graph.dependents["a"])
+}
+
+func TestComponentGraphDependencies(t *testing.T) {
+	slow := &mockComponent{}
+	cache := &mockComponent{}
+	seed := &mockComponent{}
+	service := &mockComponent{}
+
+	// slow does not finish starting until seed starts,
+	// so start all can only finish if seed does not wait for it
+	release := make(chan struct{})
+	slow.onStart = func() {
+		<-release
+	}
+	seed.onStart = func() {
+		assert.True(t, cache.startCalled)
+		close(release)
+	}
+	service.onStart = func() {
+		assert.True(t, slow.startCalled)
+		assert.True(t, seed.startCalled)
+	}
+	service.onStop = func() {
+		assert.False(t, slow.stopCalled)
+		assert.False(t, seed.stopCalled)
+	}
+	seed.onStop = func() {
+		assert.False(t, cache.stopCalled)
+	}
+
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().
+			AddComponent("service", service, "slow").
+			AddComponent("slow", slow).
+			AddComponent("cache", cache).
+			AddComponent("seed", seed, "cache").
+			DependsOn("service", "seed"),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"cache", "slow"}, {"seed"}, {"service"}}, env.graph.layers)
+
+	done := make(chan error)
+	go func() {
+		done <- env.StartAll(context.Background())
+	}()
+	select {
+	case err = <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("start all did not finish")
+	}
+	assert.True(t, service.startCalled)
+
+	err = env.StopAll(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, cache.stopCalled)
+	assert.True(t, slow.stopCalled)
+}
+
+func TestComponentGraphValidation(t *testing.T) {
+	// Setup with a dependency cycle
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().
+			AddComponent("a", &mockComponent{}, "c").
+			AddComponent("b", &mockComponent{}, "a").
+			AddComponent("c", &mockComponent{}, "b"),
+	)
+	assert.Error(t, err)
+	assert.IsType(t, ErrDependencyCycle{}, err)
+	assert.Contains(t, err.Error(), "a -> c -> b -> a")
+	assert.Nil(t, env)
+
+	// Setup with a self dependency
+	env, err = NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("a", &mockComponent{}, "a"),
+	)
+	assert.Error(t, err)
+	assert.IsType(t, ErrDependencyCycle{}, err)
+	assert.Nil(t, env)
+
+	// Setup with a missing dependency
+	env, err = NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("a", &mockComponent{}, "missing"),
+	)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "missing")
+	assert.Nil(t, env)
+
+	// Setup with dependencies of a missing component
+	env, err = NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("a", &mockComponent{}).DependsOn("missing", "a"),
+	)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "missing")
+	assert.Nil(t, env)
+}
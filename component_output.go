@@ -0,0 +1,130 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ComponentOutput provides access to the output of a single component, see Environment.ComponentOutput.
+// It works for the output of any component, as written via its Writer.
+type ComponentOutput struct {
+	id            string
+	outputManager *outputManager
+}
+
+// ComponentOutput returns the output of the component with the given ID.
+// It returns ErrInvalidComponentID if there is no such component.
+func (b *Environment) ComponentOutput(componentID string) (*ComponentOutput, error) {
+	_, err := b.componentByID(componentID)
+	if err != nil {
+		return nil, err
+	}
+	return &ComponentOutput{id: componentID, outputManager: b.outputManager}, nil
+}
+
+// Reader returns a reader for the output of the component. Like Environment.Output, the reader starts with all output
+// of the component kept in memory, and options may start from a later point or filter the output further.
+// ForComponents options are ignored.
+func (c *ComponentOutput) Reader(options ...ReaderOption) *Reader {
+	return c.outputManager.reader(c.options(options)...)
+}
+
+// Records returns the lines of output of the component kept in memory that match options, oldest first.
+// FromLastLines limits the result to the last lines, and ForComponents options are ignored.
+func (c *ComponentOutput) Records(options ...ReaderOption) []OutputRecord {
+	matches := c.outputManager.search(0, c.options(options)...)
+	result := make([]OutputRecord, 0, len(matches))
+	for _, match := range matches {
+		result = append(result, match.Record)
+	}
+	return result
+}
+
+// WaitFor waits until the component writes a line of output that matches matcher, and returns it.
+// Lines kept in memory are matched as well, unless options exclude them, e.g. FromTime(time.Now()) only matches
+// lines written from now on. It returns ErrOutputWaitTimeout if no line matches within timeout, where zero means
+// no timeout, or the error of ctx if it is done first.
+func (c *ComponentOutput) WaitFor(
+	ctx context.Context,
+	timeout time.Duration,
+	matcher LineMatcher,
+	options ...ReaderOption,
+) (OutputRecord, error) {
+	// matching lines are filtered as they are written, so waiting never misses a line since the reader fell behind
+	reader := c.Reader(append(append([]ReaderOption{}, options...), func(o *readerOptions) {
+		o.matcher = matcher
+	})...)
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case record, ok := <-reader.Records():
+			if !ok {
+				return OutputRecord{}, fmt.Errorf("output of %s was closed without a line matching %s", c.id, matcher)
+			}
+			if matcher.Match(record.Text) {
+				return record, nil
+			}
+		case <-deadline:
+			return OutputRecord{}, ErrOutputWaitTimeout{id: c.id, matcher: matcher.String(), timeout: timeout}
+		case <-ctx.Done():
+			return OutputRecord{}, ctx.Err()
+		}
+	}
+}
+
+// WaitForString waits until the component writes a line of output containing substring, and returns it.
+// See WaitFor for details.
+func (c *ComponentOutput) WaitForString(
+	ctx context.Context,
+	timeout time.Duration,
+	substring string,
+	options ...ReaderOption,
+) (OutputRecord, error) {
+	return c.WaitFor(ctx, timeout, LineContaining(substring), options...)
+}
+
+// WaitForRegex waits until the component writes a line of output matching pattern, and returns it.
+// See WaitFor for details.
+func (c *ComponentOutput) WaitForRegex(
+	ctx context.Context,
+	timeout time.Duration,
+	pattern *regexp.Regexp,
+	options ...ReaderOption,
+) (OutputRecord, error) {
+	return c.WaitFor(ctx, timeout, LineMatching(pattern), options...)
+}
+
+// options returns options scoping the output to the component, on top of the given options.
+func (c *ComponentOutput) options(options []ReaderOption) []ReaderOption {
+	return append(append([]ReaderOption{}, options...), func(o *readerOptions) {
+		o.components = map[string]bool{c.id: true}
+	})
+}
+
+// ErrOutputWaitTimeout represents an error when a component does not write a matching line of output
+// within the timeout of ComponentOutput.WaitFor.
+type ErrOutputWaitTimeout struct {
+	id      string
+	matcher string
+	timeout time.Duration
+}
+
+func (e ErrOutputWaitTimeout) Error() string {
+	return fmt.Sprintf("%s did not write a line matching %s within %s", e.id, e.matcher, e.timeout)
+}
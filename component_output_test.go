@@ -0,0 +1,83 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestComponentOutput(t *testing.T) {
+	api := &mockComponent{}
+	db := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("api", api).AddComponent("db", db))
+	assert.NoError(t, err)
+
+	_, err = env.ComponentOutput("missing")
+	assert.ErrorIs(t, err, ErrInvalidComponentID{id: "missing", msg: "not found"})
+
+	output, err := env.ComponentOutput("db")
+	assert.NoError(t, err)
+
+	api.w.WriteString("api listening")
+	db.w.WriteString("db listening on port 5432")
+	db.w.WriteString("db ready")
+	var texts []string
+	for _, record := range output.Records() {
+		texts = append(texts, record.Text)
+	}
+	assert.Equal(t, []string{"db listening on port 5432", "db ready"}, texts)
+	assert.Len(t, output.Records(ForComponents("api"), FromLastLines(1)), 1)
+	assert.Equal(t, "db ready", output.Records(FromLastLines(1))[0].Text)
+
+	reader := output.Reader(Containing("listening"))
+	assert.Equal(t, "db listening on port 5432", (<-reader.Records()).Text)
+	_ = reader.Close()
+
+	// lines kept in memory are matched
+	record, err := output.WaitForString(context.Background(), time.Second, "ready")
+	assert.NoError(t, err)
+	assert.Equal(t, "db ready", record.Text)
+	assert.Equal(t, "db", record.ComponentID)
+
+	// lines written later are matched
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		api.w.WriteString("api accepted connection 7")
+		db.w.WriteString("db accepted connection 8")
+	}()
+	record, err = output.WaitForRegex(
+		context.Background(),
+		time.Second,
+		regexp.MustCompile(`connection \d+`),
+		FromTime(time.Now()),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "db accepted connection 8", record.Text)
+
+	_, err = output.WaitForString(context.Background(), 10*time.Millisecond, "api listening")
+	assert.ErrorIs(t, err, ErrOutputWaitTimeout{id: "db", matcher: "'api listening'", timeout: 10 * time.Millisecond})
+	assert.Equal(t, "db did not write a line matching 'api listening' within 10ms", err.Error())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = output.WaitForString(ctx, 0, "never")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLineMatchers(t *testing.T) {
+	contains := LineContaining("ready")
+	assert.True(t, contains.Match("server is ready"))
+	assert.False(t, contains.Match("server is starting"))
+	assert.Equal(t, "'ready'", contains.String())
+
+	matches := LineMatching(regexp.MustCompile(`^ready on :\d+$`))
+	assert.True(t, matches.Match("ready on :8080"))
+	assert.False(t, matches.Match("not ready on :8080"))
+	assert.Equal(t, `regex '^ready on :\d+$'`, matches.String())
+}
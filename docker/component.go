@@ -98,7 +98,9 @@ func (c *Component) AttachEnvironment(ctx context.Context, env *envite.Environme
 	}
 
 	go c.writeLogs(cont.ID)
-	go c.monitorStartingStatus(cont.ID, false)
+	go func() {
+		_ = c.monitorStartingStatus(context.Background(), cont.ID, false, time.Time{})
+	}()
 
 	return nil
 }
@@ -179,13 +181,59 @@ func (c *Component) Start(ctx context.Context) error {
 		return err
 	}
 
-	c.monitorStartingStatus(id, true)
+	err = c.monitorStartingStatus(ctx, id, true, time.Time{})
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("interrupted while waiting for container to start: %w", err)
+	}
+	if c.runtimeInfo.NetworkLatency > 0 {
+		time.Sleep(c.runtimeInfo.NetworkLatency)
+	}
+	return nil
+}
+
+// Restart restarts the container of the component in place, keeping its state, and waits for it to be ready again.
+// Log based waiters only consider logs written after the restart.
+func (c *Component) Restart(ctx context.Context) error {
+	id, since, err := c.restartContainer(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.monitorStartingStatus(ctx, id, true, since)
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("interrupted while waiting for container to restart: %w", err)
+	}
 	if c.runtimeInfo.NetworkLatency > 0 {
 		time.Sleep(c.runtimeInfo.NetworkLatency)
 	}
 	return nil
 }
 
+// restartContainer restarts the container of the component, returning its ID and the time it was restarted.
+func (c *Component) restartContainer(ctx context.Context) (string, time.Time, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cont, err := c.findContainer(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if cont == nil {
+		return "", time.Time{}, fmt.Errorf("container %s does not exist", c.containerName)
+	}
+
+	since := time.Now()
+	err = c.cli.ContainerRestart(ctx, cont.ID, container.StopOptions{})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to restart container: %w", err)
+	}
+
+	// following the logs stops once the container stops, so it is resumed after the restart
+	go c.writeLogs(cont.ID)
+	return cont.ID, since, nil
+}
+
 func (c *Component) startContainer(ctx context.Context) (string, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -298,10 +346,21 @@ func (c *Component) Status(context.Context) (envite.ComponentStatus, error) {
 	return status, nil
 }
 
-func (c *Component) monitorStartingStatus(containerID string, isNewContainer bool) {
+// monitorStartingStatus runs all waiters of the component and updates its status accordingly.
+// Log based waiters ignore logs written before since, unless it is zero.
+// It returns the error of the first waiter that fails, which is also the case if ctx is canceled while waiting.
+func (c *Component) monitorStartingStatus(
+	ctx context.Context,
+	containerID string,
+	isNewContainer bool,
+	since time.Time,
+) error {
 	c.status.Store(envite.ComponentStatusStarting)
 	for _, waiter := range c.runConfig.waiters {
-		err := waiter(context.Background(), c.cli, containerID, isNewContainer)
+		err := waiter(ctx, c.cli, containerID, isNewContainer, since)
+		if err == nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
 		if err != nil {
 			// container might have been manually stopped while we waited
 			c.lock.Lock()
@@ -309,22 +368,26 @@ func (c *Component) monitorStartingStatus(containerID string, isNewContainer boo
 				c.status.Store(envite.ComponentStatusFailed)
 			}
 			c.lock.Unlock()
-			return
+			return err
 		}
 	}
 	c.status.Store(envite.ComponentStatusRunning)
+	return nil
 }
 
 func (c *Component) Config() any {
 	return c.config
 }
 
-// Exec executes a command in the Docker container.
+// Exec executes a command in the Docker container, implementing envite.Executor.
 func (c *Component) Exec(ctx context.Context, cmd []string) (int, error) {
 	cont, err := c.findContainer(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to find container: %w", err)
 	}
+	if cont == nil {
+		return 0, fmt.Errorf("container %s does not exist", c.containerName)
+	}
 
 	c.Writer().WriteString(c.Writer().Color.Cyan(fmt.Sprintf("executing: %s", strings.Join(cmd, " "))))
 	response, err := c.cli.ContainerExecCreate(ctx, cont.ID, types.ExecConfig{
@@ -386,12 +449,18 @@ func (c *Component) writeLogs(id string) {
 				return false
 			}
 
+			outputStream := envite.OutputStreamStdout
 			if stream == stdcopy.Stderr {
-				text = c.Writer().Color.Red(text)
+				outputStream = envite.OutputStreamStderr
 			}
 
 			c.latestLogMessage = timestamp
-			c.Writer().WriteStringWithTime(timestamp, text)
+			c.Writer().WriteRecord(envite.OutputRecord{
+				Time:   timestamp,
+				Stream: outputStream,
+				Level:  envite.LogLevelInfo,
+				Text:   text,
+			})
 			return false
 		},
 	)
@@ -9,8 +9,8 @@ import (
 	"fmt"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/perimeterx/envite"
 	"regexp"
-	"strings"
 	"time"
 )
 
@@ -39,69 +39,67 @@ func WaitForDuration(duration string) Waiter {
 }
 
 // waiterFunc is a function signature for the different types of waiters.
-type waiterFunc func(ctx context.Context, cli *client.Client, containerID string, isNewContainer bool) error
+// Waiters that match container logs ignore logs written before since, unless it is zero.
+type waiterFunc func(ctx context.Context, cli *client.Client, containerID string, isNewContainer bool, since time.Time) error
 
 // validateWaiter validates the provided waiter and returns the corresponding waiterFunc.
 func validateWaiter(w Waiter) (waiterFunc, error) {
 	switch w.Type {
 	case WaiterTypeString:
-		return func(ctx context.Context, cli *client.Client, containerID string, _ bool) error {
-			var reached bool
-			err := followLogs(ctx, cli, containerID, func(_ time.Time, text string, _ stdcopy.StdType) (stop bool) {
-				reached = strings.Contains(text, w.String)
-				return reached
-			})
-			if err != nil {
-				return err
-			}
-
-			if reached {
-				return nil
-			}
-
-			return ErrContainerStopped{without: fmt.Sprintf("reaching log '%s'", w.String)}
-		}, nil
+		return logWaiter(envite.LineContaining(w.String)), nil
 	case WaiterTypeRegex:
 		re, err := regexp.Compile(w.Regex)
 		if err != nil {
 			return nil, fmt.Errorf("failed to compile regex: %w", err)
 		}
 
-		return func(ctx context.Context, cli *client.Client, containerID string, _ bool) error {
-			var reached bool
-			err := followLogs(ctx, cli, containerID, func(_ time.Time, text string, _ stdcopy.StdType) (stop bool) {
-				reached = re.MatchString(text)
-				return reached
-			})
-			if err != nil {
-				return err
-			}
-
-			if reached {
-				return nil
-			}
-
-			return ErrContainerStopped{without: fmt.Sprintf("reaching log regex '%s'", w.String)}
-		}, nil
+		return logWaiter(envite.LineMatching(re)), nil
 	case WaiterTypeDuration:
 		d, err := time.ParseDuration(w.Duration)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse duration: %w", err)
 		}
 
-		return func(_ context.Context, _ *client.Client, _ string, isNewContainer bool) error {
+		return func(ctx context.Context, _ *client.Client, _ string, isNewContainer bool, _ time.Time) error {
 			if !isNewContainer {
 				return nil
 			}
 
-			time.Sleep(d)
-			return nil
+			select {
+			case <-time.After(d):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}, nil
 	}
 
 	return nil, ErrInvalidWaiterType{Type: w.Type}
 }
 
+// logWaiter returns a waiterFunc waiting until a line of the container logs matches matcher.
+func logWaiter(matcher envite.LineMatcher) waiterFunc {
+	return func(ctx context.Context, cli *client.Client, containerID string, _ bool, since time.Time) error {
+		var reached bool
+		err := followLogs(ctx, cli, containerID, func(t time.Time, text string, _ stdcopy.StdType) (stop bool) {
+			if t.Before(since) {
+				return false
+			}
+			reached = matcher.Match(text)
+			return reached
+		})
+		if err != nil {
+			return err
+		}
+
+		if reached {
+			return nil
+		}
+
+		return ErrContainerStopped{without: fmt.Sprintf("reaching log %s", matcher)}
+	}
+}
+
 // ErrInvalidWaiterType represents an error for an invalid waiter type.
 type ErrInvalidWaiterType struct {
 	Type WaiterType
@@ -9,17 +9,25 @@ import (
 	"errors"
 	"fmt"
 	"golang.org/x/sync/errgroup"
-	"sort"
 	"strings"
+	"time"
 )
 
 // Environment represents a collection of components that can be managed together.
 // Components within an environment can be started, stopped, and configured collectively or individually.
+// Lifecycle operations, such as applying state or starting a component, never run concurrently: each operation
+// waits for the running one to finish, unless requested WithoutWaiting.
 type Environment struct {
 	id             string
-	components     []map[string]Component
+	graph          *dependencyGraph
 	componentsByID map[string]Component
 	outputManager  *outputManager
+	failurePolicy  FailurePolicy
+	policies       map[string]LifecyclePolicy
+	failures       *componentFailures
+	alerts         *componentAlerts
+	events         *eventBus
+	operations     *operationLock
 	Logger         Logger
 }
 
@@ -39,40 +47,81 @@ func NewEnvironment(id string, componentGraph *ComponentGraph, options ...Option
 	om := newOutputManager()
 	b := &Environment{
 		id:             id,
-		components:     componentGraph.components,
 		componentsByID: make(map[string]Component),
 		outputManager:  om,
+		failurePolicy:  FailurePolicyFailFast,
+		policies:       make(map[string]LifecyclePolicy),
+		failures:       newComponentFailures(),
+		alerts:         newComponentAlerts(),
+		events:         newEventBus(),
+		operations:     newOperationLock(),
 	}
 
-	for _, layer := range componentGraph.components {
-		for componentID, component := range layer {
-			if componentID == "" {
-				return nil, ErrInvalidComponentID{msg: "component id may not be empty"}
-			}
-			if strings.Contains(componentID, "|") || strings.Contains(componentID, " ") {
-				return nil, ErrInvalidComponentID{id: componentID, msg: "component id may not contain '|' or ' '"}
-			}
+	for _, gc := range componentGraph.components {
+		if gc.id == "" {
+			return nil, ErrInvalidComponentID{msg: "component id may not be empty"}
+		}
+		if strings.Contains(gc.id, "|") || strings.Contains(gc.id, " ") {
+			return nil, ErrInvalidComponentID{id: gc.id, msg: "component id may not contain '|' or ' '"}
+		}
 
-			_, exists := b.componentsByID[componentID]
-			if exists {
-				return nil, ErrInvalidComponentID{id: componentID, msg: "duplicate component id"}
-			}
+		_, exists := b.componentsByID[gc.id]
+		if exists {
+			return nil, ErrInvalidComponentID{id: gc.id, msg: "duplicate component id"}
+		}
 
-			err := component.AttachEnvironment(context.Background(), b, om.writer(componentID))
-			if err != nil {
-				return nil, fmt.Errorf("failed to attach environment to component %s: %w", componentID, err)
-			}
+		b.componentsByID[gc.id] = gc.component
+	}
 
-			b.componentsByID[componentID] = component
-		}
+	graph, err := newDependencyGraph(b.componentsByID, componentGraph.dependencies)
+	if err != nil {
+		return nil, err
 	}
+	b.graph = graph
 
 	for _, option := range options {
 		option(b)
 	}
+	for componentID := range b.policies {
+		if _, ok := b.componentsByID[componentID]; !ok {
+			return nil, ErrInvalidComponentID{id: componentID, msg: "lifecycle policy set for a component that does not exist"}
+		}
+	}
+	for componentID := range om.componentLimits {
+		if _, ok := b.componentsByID[componentID]; !ok {
+			return nil, ErrInvalidComponentID{id: componentID, msg: "output limits set for a component that does not exist"}
+		}
+	}
+	for componentID := range b.alerts.rules {
+		if _, ok := b.componentsByID[componentID]; !ok {
+			return nil, ErrInvalidComponentID{id: componentID, msg: "alert rules set for a component that does not exist"}
+		}
+	}
+	err = b.alerts.validate()
+	if err != nil {
+		return nil, err
+	}
+	om.setAlerts(b.alerts)
+	if om.files != nil {
+		err = om.files.init()
+		if err != nil {
+			return nil, err
+		}
+	}
 	if b.Logger == nil {
 		b.Logger = func(LogLevel, string) {}
 	}
+	om.logger = func(level LogLevel, message string) {
+		b.Logger(level, message)
+	}
+
+	// components are attached only once the output is configured, since they may start writing output right away
+	for _, gc := range componentGraph.components {
+		err = gc.component.AttachEnvironment(context.Background(), b, om.writer(gc.id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach environment to component %s: %w", gc.id, err)
+		}
+	}
 
 	return b, nil
 }
@@ -88,127 +137,253 @@ func (b *Environment) Components() []Component {
 
 // Apply applies the specified configuration to the environment, enabling only the components with IDs in
 // enabledComponentIDs.
-// It returns an error if applying the configuration fails.
-func (b *Environment) Apply(ctx context.Context, enabledComponentIDs []string) error {
-	b.Logger(LogLevelInfo, "applying state")
-	enabledComponents := make(map[string]struct{}, len(enabledComponentIDs))
-	for _, id := range enabledComponentIDs {
-		enabledComponents[id] = struct{}{}
-	}
-	err := b.apply(ctx, enabledComponents)
-	if err != nil {
-		return err
-	}
+// Failures are handled according to the FailurePolicy of the environment, see WithFailurePolicy.
+// If any component fails, it returns an *ApplyError describing the outcome of each component.
+func (b *Environment) Apply(ctx context.Context, enabledComponentIDs []string, options ...OperationOption) error {
+	return b.operate(ctx, OperationApply, "", options, func() error {
+		b.Logger(LogLevelInfo, "applying state")
+		enabledComponents := make(map[string]struct{}, len(enabledComponentIDs))
+		for _, id := range enabledComponentIDs {
+			enabledComponents[id] = struct{}{}
+		}
+		err := b.apply(ctx, enabledComponents)
+		if err != nil {
+			return err
+		}
 
-	b.Logger(LogLevelInfo, "finished applying state")
-	return nil
+		b.Logger(LogLevelInfo, "finished applying state")
+		return nil
+	})
 }
 
-// StartAll starts all components in the environment concurrently.
-// It returns an error if starting any component fails.
-func (b *Environment) StartAll(ctx context.Context) error {
-	b.Logger(LogLevelInfo, "starting all")
-	all := make(map[string]struct{}, len(b.componentsByID))
-	for id := range b.componentsByID {
-		all[id] = struct{}{}
-	}
-	err := b.apply(ctx, all)
-	if err != nil {
-		return err
-	}
+// StartAll starts all components in the environment concurrently, according to their dependencies.
+// Failures are handled according to the FailurePolicy of the environment, see WithFailurePolicy.
+// If any component fails, it returns an *ApplyError describing the outcome of each component.
+func (b *Environment) StartAll(ctx context.Context, options ...OperationOption) error {
+	return b.operate(ctx, OperationStartAll, "", options, func() error {
+		b.Logger(LogLevelInfo, "starting all")
+		err := b.apply(ctx, b.componentIDs(nil))
+		if err != nil {
+			return err
+		}
 
-	b.Logger(LogLevelInfo, "finished starting all")
-	return nil
+		b.Logger(LogLevelInfo, "finished starting all")
+		return nil
+	})
 }
 
 // StopAll stops all components in the environment in reverse order of their startup.
+// Each component is stopped as soon as all components depending on it are stopped.
 // It returns an error if stopping any component fails.
-func (b *Environment) StopAll(ctx context.Context) error {
-	b.Logger(LogLevelInfo, "stopping all")
-	for i := len(b.components) - 1; i >= 0; i-- {
-		layer := b.components[i]
-		g, ctx := errgroup.WithContext(ctx)
-		for id, component := range layer {
-			id := id
-			component := component
-			g.Go(func() error {
-				b.Logger(LogLevelInfo, fmt.Sprintf("stopping %s", id))
-				err := component.Stop(ctx)
-				if err != nil {
-					return fmt.Errorf("could not stop %s: %w", id, err)
-				}
-
-				return nil
-			})
-		}
-		err := g.Wait()
+func (b *Environment) StopAll(ctx context.Context, options ...OperationOption) error {
+	return b.operate(ctx, OperationStopAll, "", options, func() error {
+		b.Logger(LogLevelInfo, "stopping all")
+		err := b.schedule(ctx, b.componentIDs(nil), true, false, b.stopper(newOperationOutcomes()))
 		if err != nil {
 			return err
 		}
-	}
 
-	b.Logger(LogLevelInfo, "finished stopping all")
-	return nil
+		b.Logger(LogLevelInfo, "finished stopping all")
+		return nil
+	})
 }
 
 // StartComponent starts a single component identified by componentID.
 // It does nothing if the component is already running.
-// Returns an error if the component fails to start.
-func (b *Environment) StartComponent(ctx context.Context, componentID string) error {
-	component, err := b.componentByID(componentID)
-	if err != nil {
+// If WithDependencies is provided, all components it transitively depends on are started first, in dependency order.
+// If WithCascade is provided and the component was started, all running components that transitively depend on it
+// are restarted afterward, see CascadePlan.
+// It returns the IDs of the components that were started or restarted, in the order of their dependencies,
+// and an error if any of them fails.
+func (b *Environment) StartComponent(ctx context.Context, componentID string, options ...OperationOption) ([]string, error) {
+	var started []string
+	err := b.operate(ctx, OperationStartComponent, componentID, options, func() error {
+		_, err := b.componentByID(componentID)
+		if err != nil {
+			return err
+		}
+
+		opts := newOperationOptions(options)
+		ids := map[string]struct{}{componentID: {}}
+		if opts.withDependencies {
+			for id := range b.graph.transitive(componentID, b.graph.dependencies) {
+				ids[id] = struct{}{}
+			}
+		}
+
+		outcomes := newOperationOutcomes()
+		err = b.prepare(ctx, ids, false, outcomes)
+		if err != nil {
+			return err
+		}
+
+		err = b.schedule(ctx, ids, false, false, b.starter(outcomes))
+		started = b.graph.sorted(outcomes.ids(ComponentActionStart, ComponentResultSucceeded), false)
+		if err != nil || !opts.cascade {
+			return err
+		}
+
+		if outcome, _ := outcomes.get(componentID); outcome.Result != ComponentResultSucceeded {
+			return nil
+		}
+
+		restarted, err := b.cascade(ctx, componentID)
+		started = append(started, restarted...)
 		return err
-	}
+	})
+	return started, err
+}
 
-	status, err := component.Status(ctx)
-	if err != nil {
+// StopComponent stops a single component identified by componentID.
+// If WithDependents is provided, all components that transitively depend on it are stopped first,
+// in reverse dependency order.
+// It returns the IDs of the components that were stopped, in the order they were stopped,
+// and an error if any of them fails to stop.
+func (b *Environment) StopComponent(ctx context.Context, componentID string, options ...OperationOption) ([]string, error) {
+	var stopped []string
+	err := b.operate(ctx, OperationStopComponent, componentID, options, func() error {
+		_, err := b.componentByID(componentID)
+		if err != nil {
+			return err
+		}
+
+		opts := newOperationOptions(options)
+		ids := map[string]struct{}{componentID: {}}
+		if opts.withDependents {
+			for id := range b.graph.transitive(componentID, b.graph.dependents) {
+				ids[id] = struct{}{}
+			}
+		}
+
+		outcomes := newOperationOutcomes()
+		err = b.schedule(ctx, ids, true, false, b.stopper(outcomes))
+		stopped = b.graph.sorted(outcomes.ids(ComponentActionStop, ComponentResultSucceeded), true)
 		return err
-	}
+	})
+	return stopped, err
+}
 
-	if status == ComponentStatusRunning || status == ComponentStatusStarting {
+// RestartAll restarts all components in the environment, in the order of their dependencies.
+// Running components implementing Restarter are restarted in place, while any other running component is
+// stopped, prepared and started again. Components that are not running are prepared and started.
+// It returns an error if restarting any component fails.
+func (b *Environment) RestartAll(ctx context.Context, options ...OperationOption) error {
+	return b.operate(ctx, OperationRestartAll, "", options, func() error {
+		b.Logger(LogLevelInfo, "restarting all")
+		_, err := b.restartComponents(ctx, b.componentIDs(nil))
+		if err != nil {
+			return err
+		}
+
+		b.Logger(LogLevelInfo, "finished restarting all")
 		return nil
-	}
+	})
+}
 
-	b.Logger(LogLevelInfo, fmt.Sprintf("preparing %s", componentID))
-	err = component.Prepare(ctx)
-	if err != nil {
+// RestartComponent restarts a single component identified by componentID.
+// If the component implements Restarter it is restarted in place, otherwise it is stopped, prepared and started again.
+// If the component is not running, it is prepared and started.
+// If WithCascade is provided, all running components that transitively depend on it are restarted afterward,
+// see CascadePlan.
+// It returns the IDs of the components that were restarted, in the order of their dependencies,
+// and an error if restarting any of them fails.
+func (b *Environment) RestartComponent(ctx context.Context, componentID string, options ...OperationOption) ([]string, error) {
+	var restarted []string
+	err := b.operate(ctx, OperationRestartComponent, componentID, options, func() error {
+		_, err := b.componentByID(componentID)
+		if err != nil {
+			return err
+		}
+
+		restarted, err = b.restartComponents(ctx, map[string]struct{}{componentID: {}})
+		if err != nil || !newOperationOptions(options).cascade {
+			return err
+		}
+
+		cascaded, err := b.cascade(ctx, componentID)
+		restarted = append(restarted, cascaded...)
 		return err
-	}
+	})
+	return restarted, err
+}
 
-	b.Logger(LogLevelInfo, fmt.Sprintf("starting %s", componentID))
-	err = component.Start(ctx)
+// ExecComponent executes cmd in the component identified by componentID, and returns its exit code.
+// The component must implement Executor. Commands are not lifecycle operations, so they may run concurrently
+// with any operation.
+func (b *Environment) ExecComponent(ctx context.Context, componentID string, cmd []string) (int, error) {
+	component, err := b.componentByID(componentID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	b.Logger(LogLevelInfo, fmt.Sprintf("finished starting %s", componentID))
-	return nil
+	executor, ok := component.(Executor)
+	if !ok {
+		return 0, ErrExecNotSupported{id: componentID}
+	}
+	return executor.Exec(ctx, cmd)
 }
 
-// StopComponent stops a single component identified by componentID.
-// Returns an error if the component fails to stop.
-func (b *Environment) StopComponent(ctx context.Context, componentID string) error {
-	component, err := b.componentByID(componentID)
-	if err != nil {
-		return err
+// Plan returns the actions Apply would take on each component given the same enabledComponentIDs,
+// based on the current status of the components, without changing anything.
+func (b *Environment) Plan(ctx context.Context, enabledComponentIDs []string) (Plan, error) {
+	enabled := make(map[string]struct{}, len(enabledComponentIDs))
+	for _, id := range enabledComponentIDs {
+		enabled[id] = struct{}{}
+	}
+
+	result := Plan{Components: make([]PlannedComponent, 0, len(b.graph.ids))}
+	for _, layer := range b.graph.layers {
+		for _, id := range layer {
+			status, err := b.componentsByID[id].Status(ctx)
+			if err != nil {
+				return Plan{}, fmt.Errorf("could not get status for %s: %w", id, err)
+			}
+
+			c := PlannedComponent{ID: id, Status: status, Actions: []ComponentAction{}}
+			running := status == ComponentStatusRunning || status == ComponentStatusStarting
+			_, ok := enabled[id]
+			switch {
+			case ok && !running:
+				c.Actions = append(c.Actions, ComponentActionPrepare, ComponentActionStart)
+			case !ok && status != ComponentStatusStopped:
+				c.Actions = append(c.Actions, ComponentActionStop)
+			}
+			result.Components = append(result.Components, c)
+		}
 	}
+	return result, nil
+}
 
-	b.Logger(LogLevelInfo, fmt.Sprintf("stopping %s", componentID))
-	err = component.Stop(ctx)
+// CascadePlan returns the IDs of the components that would be restarted following a change to the component
+// identified by componentID, when starting or restarting it WithCascade.
+// These are all running components that transitively depend on it, in the order they would be restarted.
+func (b *Environment) CascadePlan(ctx context.Context, componentID string) ([]string, error) {
+	_, err := b.componentByID(componentID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	b.Logger(LogLevelInfo, fmt.Sprintf("finished stopping %s", componentID))
-	return nil
+	ids := make(map[string]struct{})
+	for id := range b.graph.transitive(componentID, b.graph.dependents) {
+		status, err := b.componentsByID[id].Status(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not get status for %s: %w", id, err)
+		}
+
+		if status == ComponentStatusRunning || status == ComponentStatusStarting {
+			ids[id] = struct{}{}
+		}
+	}
+	return b.graph.sorted(ids, false), nil
 }
 
 // Status returns the current status of all components within the environment.
 func (b *Environment) Status(ctx context.Context) (GetStatusResponse, error) {
-	result := GetStatusResponse{ID: b.id, Components: make([][]GetStatusResponseComponent, len(b.components))}
-	for i, layer := range b.components {
+	result := GetStatusResponse{ID: b.id, Components: make([][]GetStatusResponseComponent, len(b.graph.layers))}
+	for i, layer := range b.graph.layers {
 		components := make([]GetStatusResponseComponent, 0, len(layer))
-		for id, component := range layer {
+		for _, id := range layer {
+			component := b.componentsByID[id]
 			status, err := component.Status(ctx)
 			if err != nil {
 				return GetStatusResponse{}, fmt.Errorf("could not get status for %s: %w", id, err)
@@ -219,144 +394,556 @@ func (b *Environment) Status(ctx context.Context) (GetStatusResponse, error) {
 				return GetStatusResponse{}, fmt.Errorf("failed to build component info for %s: %w", id, err)
 			}
 
-			components = append(components, GetStatusResponseComponent{
+			c := GetStatusResponseComponent{
 				ID:     id,
 				Type:   component.Type(),
 				Status: status,
 				Config: info,
-			})
+			}
+			if alerts, ok := b.alerts.get(id); ok {
+				c.Alerts = &alerts
+			}
+			if failure, ok := b.failures.get(id); ok {
+				c.Status = ComponentStatusFailed
+				c.FailureReason = failure.reason
+				c.Error = failure.err
+			}
+			components = append(components, c)
 		}
 
-		// since components of each layer are stored in a map,
-		// their order is shuffled each time we produce status.
-		// to allow the ordering of components to be consistent,
-		// we sort them lexicographically by their ID:
-		sort.Slice(components, func(i, j int) bool {
-			return components[i].ID < components[j].ID
-		})
-
 		result.Components[i] = components
 	}
+	if running, ok := b.operations.get(); ok {
+		result.Operation = &running
+	}
 	return result, nil
 }
 
+// Subscribe returns a Subscription receiving all lifecycle events of the environment from now on,
+// such as components being started or stopped, and operations starting and finishing.
+// The subscription must be closed once no longer used.
+func (b *Environment) Subscribe() *Subscription {
+	return b.events.subscribe()
+}
+
+// CurrentOperation returns the lifecycle operation currently running on the environment, if any.
+func (b *Environment) CurrentOperation() (RunningOperation, bool) {
+	return b.operations.get()
+}
+
 // Output returns a reader for the environment's combined output from all components.
-func (b *Environment) Output() *Reader {
-	return b.outputManager.reader()
+// By default, the reader starts with all output kept in memory, see ReaderOption to start from a later point
+// or to filter the output.
+func (b *Environment) Output(options ...ReaderOption) *Reader {
+	return b.outputManager.reader(options...)
+}
+
+// SearchOutput returns the lines of output kept in memory that match options, oldest first, each along with up to
+// contextLines lines the same component wrote before and after it. FromLastLines limits the result to the last
+// matches, and options that only affect streaming, such as DisconnectIfSlow, are ignored.
+func (b *Environment) SearchOutput(contextLines int, options ...ReaderOption) []OutputMatch {
+	return b.outputManager.search(contextLines, options...)
+}
+
+// CloseOutput writes any buffered output to the spill file, and closes it along with the log files output is mirrored
+// to, see WithOutputSpillFile and WithLogFiles. Output written afterward opens them again, so CloseOutput can be called
+// whenever the files should be complete, and should be called once the environment is no longer used.
+// Server.Close and Execute call it once they are done.
+func (b *Environment) CloseOutput() error {
+	return b.outputManager.close()
 }
 
 // Cleanup performs cleanup operations for all components within the environment.
+// Each component is cleaned up as soon as all components depending on it are cleaned up.
 // It returns an error if cleaning up any component fails.
-func (b *Environment) Cleanup(ctx context.Context) error {
-	b.Logger(LogLevelInfo, "cleaning up")
-	g, ctx := errgroup.WithContext(ctx)
-	for _, layer := range b.components {
-		for id, component := range layer {
-			id := id
-			component := component
-			g.Go(func() error {
-				b.Logger(LogLevelInfo, fmt.Sprintf("cleaning up %s", id))
-				err := component.Cleanup(ctx)
+func (b *Environment) Cleanup(ctx context.Context, options ...OperationOption) error {
+	return b.operate(ctx, OperationCleanup, "", options, func() error {
+		b.Logger(LogLevelInfo, "cleaning up")
+		err := b.schedule(ctx, b.componentIDs(nil), true, false, b.cleaner)
+		if err != nil {
+			return err
+		}
+
+		b.Logger(LogLevelInfo, "finished cleaning up")
+		return nil
+	})
+}
+
+func (b *Environment) apply(ctx context.Context, enabledComponentIDs map[string]struct{}) error {
+	continueOnError := b.failurePolicy == FailurePolicyContinue
+	enabled := b.componentIDs(func(id string) bool {
+		_, ok := enabledComponentIDs[id]
+		return ok
+	})
+	disabled := b.componentIDs(func(id string) bool {
+		_, ok := enabledComponentIDs[id]
+		return !ok
+	})
+
+	outcomes := newOperationOutcomes()
+	err := b.prepare(ctx, enabled, continueOnError, outcomes)
+	if err == nil || continueOnError {
+		err = b.schedule(ctx, disabled, true, continueOnError, b.stopper(outcomes))
+	}
+	if err == nil || continueOnError {
+		err = b.schedule(ctx, enabled, false, continueOnError, b.starter(outcomes))
+	}
+
+	if !outcomes.failed() {
+		return err
+	}
+
+	if b.failurePolicy == FailurePolicyRollback {
+		b.rollback(outcomes)
+	}
+
+	return &ApplyError{
+		Policy: b.failurePolicy,
+		Components: outcomes.list(b.graph, func(id string) ComponentAction {
+			if _, ok := enabled[id]; ok {
+				return ComponentActionStart
+			}
+			return ComponentActionStop
+		}),
+	}
+}
+
+// rollback stops all components that were started during an operation, in reverse dependency order.
+// Components that failed or were canceled while starting are stopped as well, as they might have been partially
+// started.
+func (b *Environment) rollback(outcomes *operationOutcomes) {
+	ids := outcomes.ids(
+		ComponentActionStart,
+		ComponentResultSucceeded,
+		ComponentResultFailed,
+		ComponentResultCanceled,
+	)
+	if len(ids) == 0 {
+		return
+	}
+
+	b.Logger(LogLevelInfo, "rolling back")
+	_ = b.schedule(context.Background(), ids, true, true, func(ctx context.Context, id string, component Component) error {
+		outcome, _ := outcomes.get(id)
+		err := b.stop(ctx, id, component)
+		if err != nil {
+			// rollback is best effort, so a failure is only recorded, and does not prevent
+			// the dependencies of this component from being rolled back as well
+			b.Logger(LogLevelError, fmt.Sprintf("could not roll back %s: %v", id, err))
+			if outcome.Result == ComponentResultSucceeded {
+				outcomes.record(id, ComponentActionStart, ComponentResultRollbackFailed, err)
+			}
+			return nil
+		}
+
+		if outcome.Result == ComponentResultSucceeded {
+			outcomes.record(id, ComponentActionStart, ComponentResultRolledBack, nil)
+		}
+		return nil
+	})
+	b.Logger(LogLevelInfo, "finished rolling back")
+}
+
+// prepare prepares all components in ids that are not already running, recording their outcomes.
+// Unless continueOnError is true, the first failure cancels preparing all other components.
+func (b *Environment) prepare(
+	ctx context.Context,
+	ids map[string]struct{},
+	continueOnError bool,
+	outcomes *operationOutcomes,
+) error {
+	g := &errgroup.Group{}
+	if !continueOnError {
+		g, ctx = errgroup.WithContext(ctx)
+	}
+	for _, id := range b.graph.ids {
+		_, ok := ids[id]
+		if !ok {
+			continue
+		}
+		id := id
+		component := b.componentsByID[id]
+		g.Go(func() error {
+			return b.track(ctx, outcomes, id, ComponentActionPrepare, func() (bool, error) {
+				status, err := component.Status(ctx)
 				if err != nil {
-					return fmt.Errorf("could not cleanup %s: %w", id, err)
+					return false, fmt.Errorf("could not get status for %s: %w", id, err)
 				}
 
-				return nil
+				if status == ComponentStatusRunning || status == ComponentStatusStarting {
+					return false, nil
+				}
+
+				err = b.prepareComponent(ctx, id, component)
+				if err != nil {
+					return false, err
+				}
+				return true, nil
 			})
-		}
+		})
 	}
-	err := g.Wait()
+	return g.Wait()
+}
+
+// prepareComponent prepares a single component, retrying according to its LifecyclePolicy.
+func (b *Environment) prepareComponent(ctx context.Context, id string, component Component) error {
+	b.Logger(LogLevelInfo, fmt.Sprintf("preparing %s", id))
+	startTime := time.Now()
+	err := b.withRetries(ctx, id, ComponentActionPrepare, func() error {
+		return component.Prepare(ctx)
+	})
 	if err != nil {
+		err = fmt.Errorf("could not prepare %s: %w", id, err)
+		b.publishComponent(EventComponentFailed, id, startTime, err)
 		return err
 	}
 
-	b.Logger(LogLevelInfo, "finished cleaning up")
+	b.Logger(LogLevelInfo, fmt.Sprintf("finished preparing %s", id))
+	b.publishComponent(EventComponentPrepared, id, startTime, nil)
 	return nil
 }
 
-func (b *Environment) apply(ctx context.Context, enabledComponentIDs map[string]struct{}) error {
-	err := b.prepare(ctx, enabledComponentIDs)
+// starter returns a scheduled operation that starts a component, recording its outcome.
+// Components that already failed earlier in the operation, e.g. while being prepared, are not started.
+func (b *Environment) starter(outcomes *operationOutcomes) func(context.Context, string, Component) error {
+	return func(ctx context.Context, id string, component Component) error {
+		if outcome, ok := outcomes.get(id); ok && outcome.err != nil {
+			return outcome.err
+		}
+
+		return b.track(ctx, outcomes, id, ComponentActionStart, func() (bool, error) {
+			return b.start(ctx, id, component)
+		})
+	}
+}
+
+// cascade restarts all running components that transitively depend on the component identified by componentID,
+// after it changed. The plan is logged and published as an EventCascadePlanned before it is executed.
+// It returns the IDs of the components that were restarted.
+func (b *Environment) cascade(ctx context.Context, componentID string) ([]string, error) {
+	plan, err := b.CascadePlan(ctx, componentID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, layer := range b.components {
-		g, ctx := errgroup.WithContext(ctx)
-		for id, component := range layer {
-			id := id
-			component := component
-			_, ok := enabledComponentIDs[id]
-			if ok {
-				g.Go(func() error {
-					status, err := component.Status(ctx)
-					if err != nil {
-						return fmt.Errorf("could not get status for %s: %w", id, err)
-					}
+	b.Logger(LogLevelInfo, fmt.Sprintf("change of %s cascades to [%s]", componentID, strings.Join(plan, ", ")))
+	b.publish(Event{
+		Type:        EventCascadePlanned,
+		Time:        time.Now(),
+		Operation:   b.runningOperation(),
+		ComponentID: componentID,
+		Components:  plan,
+	})
+	if len(plan) == 0 {
+		return nil, nil
+	}
 
-					if status == ComponentStatusRunning || status == ComponentStatusStarting {
-						return nil
-					}
+	ids := make(map[string]struct{}, len(plan))
+	for _, id := range plan {
+		ids[id] = struct{}{}
+	}
+	return b.restartComponents(ctx, ids)
+}
 
-					b.Logger(LogLevelInfo, fmt.Sprintf("starting %s", id))
-					err = component.Start(ctx)
-					if err != nil {
-						return fmt.Errorf("could not start %s: %w", id, err)
-					}
+// restartComponents prepares and restarts the components identified by ids, in the order of their dependencies.
+// It returns the IDs of the components that were restarted.
+func (b *Environment) restartComponents(ctx context.Context, ids map[string]struct{}) ([]string, error) {
+	outcomes := newOperationOutcomes()
+	err := b.prepare(ctx, ids, false, outcomes)
+	if err != nil {
+		return nil, err
+	}
 
-					b.Logger(LogLevelInfo, fmt.Sprintf("finished starting %s", id))
-					return nil
-				})
-			} else {
-				g.Go(func() error {
-					b.Logger(LogLevelInfo, fmt.Sprintf("stopping %s", id))
-					err := component.Stop(ctx)
-					if err != nil {
-						return fmt.Errorf("could not stop %s: %w", id, err)
-					}
+	err = b.schedule(ctx, ids, false, false, func(ctx context.Context, id string, component Component) error {
+		return b.track(ctx, outcomes, id, ComponentActionRestart, func() (bool, error) {
+			return b.restart(ctx, id, component)
+		})
+	})
+	return b.graph.sorted(outcomes.ids(ComponentActionRestart, ComponentResultSucceeded), false), err
+}
 
-					b.Logger(LogLevelInfo, fmt.Sprintf("finished stopping %s", id))
-					return nil
-				})
-			}
+// stopper returns a scheduled operation that stops a component, recording its outcome.
+func (b *Environment) stopper(outcomes *operationOutcomes) func(context.Context, string, Component) error {
+	return func(ctx context.Context, id string, component Component) error {
+		return b.track(ctx, outcomes, id, ComponentActionStop, func() (bool, error) {
+			return true, b.stop(ctx, id, component)
+		})
+	}
+}
+
+// track runs a single action on a component and records its outcome.
+// fn returns true if the action changed the component, or false if no change was needed.
+func (b *Environment) track(
+	ctx context.Context,
+	outcomes *operationOutcomes,
+	id string,
+	action ComponentAction,
+	fn func() (bool, error),
+) error {
+	changed, err := fn()
+	switch {
+	case err != nil && ctx.Err() != nil:
+		outcomes.record(id, action, ComponentResultCanceled, err)
+	case err != nil:
+		outcomes.record(id, action, ComponentResultFailed, err)
+		b.failures.set(id, action, err)
+	case changed:
+		outcomes.record(id, action, ComponentResultSucceeded, nil)
+		b.failures.clear(id)
+	default:
+		outcomes.record(id, action, ComponentResultUnchanged, nil)
+		b.failures.clear(id)
+	}
+	return err
+}
+
+// start starts a single component as part of a scheduled operation, unless it is already running.
+// It returns true if the component was started.
+func (b *Environment) start(ctx context.Context, id string, component Component) (bool, error) {
+	status, err := component.Status(ctx)
+	if err != nil {
+		return false, fmt.Errorf("could not get status for %s: %w", id, err)
+	}
+
+	if status == ComponentStatusRunning || status == ComponentStatusStarting {
+		return false, nil
+	}
+
+	b.Logger(LogLevelInfo, fmt.Sprintf("starting %s", id))
+	startTime := time.Now()
+	b.publishComponent(EventComponentStarting, id, time.Time{}, nil)
+	b.alerts.clear(id)
+	err = b.withRetries(ctx, id, ComponentActionStart, func() error {
+		return withTimeout(ctx, id, ComponentActionStart, b.policies[id].StartTimeout, component.Start)
+	})
+	if err != nil {
+		err = fmt.Errorf("could not start %s: %w", id, err)
+		b.publishComponent(EventComponentFailed, id, startTime, err)
+		return false, err
+	}
+
+	b.Logger(LogLevelInfo, fmt.Sprintf("finished starting %s", id))
+	b.publishComponent(EventComponentRunning, id, startTime, nil)
+	return true, nil
+}
+
+// restart restarts a single component as part of a scheduled operation, or starts it if it is not running.
+// Running components that do not implement Restarter are stopped, prepared and started, as StartComponent would.
+// It returns true if the component was restarted or started.
+func (b *Environment) restart(ctx context.Context, id string, component Component) (bool, error) {
+	status, err := component.Status(ctx)
+	if err != nil {
+		return false, fmt.Errorf("could not get status for %s: %w", id, err)
+	}
+
+	if status != ComponentStatusRunning && status != ComponentStatusStarting {
+		return b.start(ctx, id, component)
+	}
+
+	restarter, ok := component.(Restarter)
+	if !ok {
+		err = b.stop(ctx, id, component)
+		if err != nil {
+			return false, err
 		}
-		err := g.Wait()
+
+		err = b.prepareComponent(ctx, id, component)
 		if err != nil {
-			return err
+			return false, err
 		}
+
+		return b.start(ctx, id, component)
 	}
+
+	b.Logger(LogLevelInfo, fmt.Sprintf("restarting %s", id))
+	startTime := time.Now()
+	b.publishComponent(EventComponentRestarting, id, time.Time{}, nil)
+	b.alerts.clear(id)
+	err = b.withRetries(ctx, id, ComponentActionRestart, func() error {
+		return withTimeout(ctx, id, ComponentActionRestart, b.policies[id].StartTimeout, restarter.Restart)
+	})
+	if err != nil {
+		err = fmt.Errorf("could not restart %s: %w", id, err)
+		b.publishComponent(EventComponentFailed, id, startTime, err)
+		return false, err
+	}
+
+	b.Logger(LogLevelInfo, fmt.Sprintf("finished restarting %s", id))
+	b.publishComponent(EventComponentRunning, id, startTime, nil)
+	return true, nil
+}
+
+// stop stops a single component as part of a scheduled operation.
+func (b *Environment) stop(ctx context.Context, id string, component Component) error {
+	b.Logger(LogLevelInfo, fmt.Sprintf("stopping %s", id))
+	startTime := time.Now()
+	err := withTimeout(ctx, id, ComponentActionStop, b.policies[id].StopTimeout, component.Stop)
+	if err != nil {
+		err = fmt.Errorf("could not stop %s: %w", id, err)
+		b.publishComponent(EventComponentFailed, id, startTime, err)
+		return err
+	}
+
+	b.Logger(LogLevelInfo, fmt.Sprintf("finished stopping %s", id))
+	b.publishComponent(EventComponentStopped, id, startTime, nil)
 	return nil
 }
 
-func (b *Environment) prepare(ctx context.Context, enabledComponentIDs map[string]struct{}) error {
-	g, ctx := errgroup.WithContext(ctx)
-	for _, layer := range b.components {
-		for id, component := range layer {
-			_, ok := enabledComponentIDs[id]
-			if !ok {
-				continue
-			}
-			id := id
-			component := component
-			g.Go(func() error {
-				status, err := component.Status(ctx)
-				if err != nil {
-					return fmt.Errorf("could not get status for %s: %w", id, err)
-				}
+// cleaner cleans up a single component as part of a scheduled operation.
+func (b *Environment) cleaner(ctx context.Context, id string, component Component) error {
+	b.Logger(LogLevelInfo, fmt.Sprintf("cleaning up %s", id))
+	startTime := time.Now()
+	err := component.Cleanup(ctx)
+	if err != nil {
+		err = fmt.Errorf("could not cleanup %s: %w", id, err)
+		b.publishComponent(EventComponentFailed, id, startTime, err)
+		return err
+	}
 
-				if status == ComponentStatusRunning || status == ComponentStatusStarting {
-					return nil
-				}
+	b.publishComponent(EventComponentCleaned, id, startTime, nil)
+	return nil
+}
 
-				b.Logger(LogLevelInfo, fmt.Sprintf("preparing %s", id))
-				err = component.Prepare(ctx)
-				if err != nil {
-					return fmt.Errorf("could not prepare %s: %w", id, err)
+// operate runs fn as a single lifecycle operation on the environment, publishing events when it starts and finishes.
+// componentID is the ID of the component the operation was requested for, if any.
+// Operations never overlap: unless WithoutWaiting is provided, operate waits for any running operation to finish first.
+func (b *Environment) operate(
+	ctx context.Context,
+	operation Operation,
+	componentID string,
+	options []OperationOption,
+	fn func() error,
+) error {
+	opts := newOperationOptions(options)
+	running, err := b.operations.acquire(ctx, operation, componentID, opts.observer, !opts.withoutWaiting)
+	if err != nil {
+		return err
+	}
+	defer b.operations.release()
+
+	startTime := running.StartedAt
+	b.publish(Event{Type: EventOperationStarted, Time: startTime, Operation: operation, ComponentID: componentID})
+	err = fn()
+	event := Event{
+		Type:        EventOperationFinished,
+		Time:        time.Now(),
+		Operation:   operation,
+		ComponentID: componentID,
+		Duration:    time.Since(startTime),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	b.publish(event)
+	return err
+}
+
+// publish publishes event to all subscribers, and to the observer of the running operation, if any.
+func (b *Environment) publish(event Event) {
+	b.events.publish(event)
+	b.operations.observe(event)
+}
+
+// publishComponent publishes an event of the given type for a component, as part of the running operation.
+// If startTime is set, the event includes the time passed since it, and if err is not nil the event includes the error.
+func (b *Environment) publishComponent(eventType EventType, componentID string, startTime time.Time, err error) {
+	event := Event{Type: eventType, Time: time.Now(), Operation: b.runningOperation(), ComponentID: componentID}
+	if !startTime.IsZero() {
+		event.Duration = event.Time.Sub(startTime)
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	b.publish(event)
+}
+
+// runningOperation returns the lifecycle operation currently running on the environment, or an empty string if none.
+func (b *Environment) runningOperation() Operation {
+	running, _ := b.operations.get()
+	return running.Operation
+}
+
+// componentIDs returns the set of component IDs for which filter returns true.
+// A nil filter returns the IDs of all components.
+func (b *Environment) componentIDs(filter func(id string) bool) map[string]struct{} {
+	result := make(map[string]struct{}, len(b.graph.ids))
+	for _, id := range b.graph.ids {
+		if filter == nil || filter(id) {
+			result[id] = struct{}{}
+		}
+	}
+	return result
+}
+
+// schedule concurrently runs fn for each component in ids, as soon as all of its dependencies are done.
+// If reverse is true, fn runs for each component as soon as all of its dependents are done instead,
+// which is the order used to stop or clean up components.
+// Components that are not in ids are not operated on, but still hold back the components that depend on
+// them (or on which they depend, in reverse) until their own dependencies are done, to preserve transitive order.
+//
+// Unless continueOnError is true, the first error cancels the context of all other operations and is returned.
+// Otherwise, operations carry on and the first error is returned once all of them are done, while
+// components that depend on a failed component (or on which it depends, in reverse) are skipped.
+func (b *Environment) schedule(
+	ctx context.Context,
+	ids map[string]struct{},
+	reverse bool,
+	continueOnError bool,
+	fn func(ctx context.Context, id string, component Component) error,
+) error {
+	type node struct {
+		done   chan struct{}
+		failed bool
+	}
+	nodes := make(map[string]*node, len(b.graph.ids))
+	for _, id := range b.graph.ids {
+		nodes[id] = &node{done: make(chan struct{})}
+	}
+
+	g := &errgroup.Group{}
+	if !continueOnError {
+		g, ctx = errgroup.WithContext(ctx)
+	}
+	for _, id := range b.graph.ids {
+		id := id
+		n := nodes[id]
+		upstream := b.graph.dependencies[id]
+		if reverse {
+			upstream = b.graph.dependents[id]
+		}
+		g.Go(func() error {
+			defer close(n.done)
+			for _, upstreamID := range upstream {
+				select {
+				case <-nodes[upstreamID].done:
+					if nodes[upstreamID].failed {
+						n.failed = true
+					}
+				case <-ctx.Done():
+					n.failed = true
+					return ctx.Err()
 				}
+			}
 
-				b.Logger(LogLevelInfo, fmt.Sprintf("finished preparing %s", id))
+			if ctx.Err() != nil {
+				n.failed = true
+				return ctx.Err()
+			}
+
+			if n.failed {
 				return nil
-			})
-		}
+			}
+
+			if _, ok := ids[id]; !ok {
+				return nil
+			}
+
+			err := fn(ctx, id, b.componentsByID[id])
+			if err != nil {
+				n.failed = true
+			}
+			return err
+		})
 	}
 	return g.Wait()
 }
@@ -386,3 +973,12 @@ type ErrInvalidComponentID struct {
 func (e ErrInvalidComponentID) Error() string {
 	return fmt.Sprintf("component id '%s' is invalid: %s", e.id, e.msg)
 }
+
+// ErrExecNotSupported represents an error when executing a command in a component that does not implement Executor.
+type ErrExecNotSupported struct {
+	id string
+}
+
+func (e ErrExecNotSupported) Error() string {
+	return fmt.Sprintf("component %s does not support executing commands", e.id)
+}
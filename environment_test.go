@@ -6,9 +6,14 @@ package envite
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -277,15 +282,17 @@ func TestEnvironmentManagement(t *testing.T) {
 	component3.onStop = nil
 
 	// Validate manual stop
-	err = env.StopComponent(context.Background(), "component-1")
+	stopped, err := env.StopComponent(context.Background(), "component-1")
 	assert.NoError(t, err)
+	assert.Equal(t, []string{"component-1"}, stopped)
 	assert.Equal(t, ComponentStatusStopped, component1.status)
 	assert.Equal(t, ComponentStatusRunning, component2.status)
 	assert.Equal(t, ComponentStatusRunning, component3.status)
 
 	// Validate manual start
-	err = env.StartComponent(context.Background(), "component-1")
+	started, err := env.StartComponent(context.Background(), "component-1")
 	assert.NoError(t, err)
+	assert.Equal(t, []string{"component-1"}, started)
 	assert.Equal(t, ComponentStatusRunning, component1.status)
 	assert.Equal(t, ComponentStatusRunning, component2.status)
 	assert.Equal(t, ComponentStatusRunning, component3.status)
@@ -496,3 +503,441 @@ func TestOutput(t *testing.T) {
 	err = reader.Close()
 	assert.NoError(t, err)
 }
+
+func TestOutputRecords(t *testing.T) {
+	component := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+
+	someTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	component.w.WriteStringWithTime(someTime, "plain message\n")
+	component.w.WriteRecord(OutputRecord{
+		ComponentID: "ignored",
+		Time:        someTime,
+		Stream:      OutputStreamStderr,
+		Level:       LogLevelError,
+		Text:        "structured message\r\n",
+		Attributes:  map[string]any{"key": "value"},
+	})
+
+	reader := env.Output()
+	assert.Equal(t, OutputRecord{
+		ComponentID: "component",
+		Time:        someTime,
+		Stream:      OutputStreamStdout,
+		Level:       LogLevelInfo,
+		Text:        "plain message",
+	}, <-reader.Records())
+	assert.Equal(t, OutputRecord{
+		ComponentID: "component",
+		Time:        someTime,
+		Stream:      OutputStreamStderr,
+		Level:       LogLevelError,
+		Text:        "structured message",
+		Attributes:  map[string]any{"key": "value"},
+	}, <-reader.Records())
+	assert.NoError(t, reader.Close())
+
+	// the legacy format has no streams, so stderr is colored instead
+	reader = env.Output(FromLastLines(1))
+	assert.Contains(t, string(<-reader.Chan()), "<msg>\u001B[31mstructured message\u001B[39m\n")
+	assert.NoError(t, reader.Close())
+
+	// levels are encoded by name
+	data, err := json.Marshal(OutputRecord{ComponentID: "component", Time: someTime, Level: LogLevelError})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"level":"error"`)
+	var record OutputRecord
+	assert.NoError(t, json.Unmarshal(data, &record))
+	assert.Equal(t, LogLevelError, record.Level)
+	assert.Error(t, json.Unmarshal([]byte(`{"level":"loud"}`), &record))
+}
+
+func TestOutputFilters(t *testing.T) {
+	api := &mockComponent{}
+	db := &mockComponent{}
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("api", api).AddComponent("db", db),
+	)
+	assert.NoError(t, err)
+
+	startTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	api.w.WriteStringWithTime(startTime, "api listening")
+	db.w.WriteStringWithTime(startTime.Add(time.Second), "db ready")
+	api.w.WriteRecord(OutputRecord{Time: startTime.Add(2 * time.Second), Stream: OutputStreamStderr, Text: "api error 1"})
+	db.w.WriteStringWithTime(startTime.Add(3*time.Second), "db query")
+	api.w.WriteRecord(OutputRecord{Time: startTime.Add(4 * time.Second), Stream: OutputStreamStderr, Text: "api error 2"})
+	api.w.WriteStringWithTime(startTime.Add(5*time.Second), "api request")
+
+	read := func(options ...ReaderOption) []string {
+		reader := env.Output(options...)
+		defer func() {
+			_ = reader.Close()
+		}()
+		var result []string
+		for {
+			select {
+			case record := <-reader.Records():
+				result = append(result, record.Text)
+			case <-time.After(100 * time.Millisecond):
+				return result
+			}
+		}
+	}
+
+	assert.Equal(t, []string{"db ready", "db query"}, read(ForComponents("db")))
+	assert.Equal(t, []string{"api error 1", "api error 2"}, read(ForStreams(OutputStreamStderr)))
+	assert.Equal(t, []string{"db ready", "api error 1"}, read(FromTime(startTime.Add(time.Second)), UntilTime(startTime.Add(2*time.Second))))
+	assert.Equal(t, []string{"db ready", "db query"}, read(Containing("db ")))
+	assert.Equal(t, []string{"api error 2", "api request"}, read(ForComponents("api"), Matching(regexp.MustCompile(`error 2|request`))))
+	assert.Equal(t, []string{"api request"}, read(ForComponents("api"), FromLastLines(1)))
+
+	// filters apply to new output as well
+	reader := env.Output(ForComponents("db"), FromLastLines(1))
+	assert.Equal(t, "db query", (<-reader.Records()).Text)
+	api.w.WriteString("api new")
+	db.w.WriteString("db new")
+	assert.Equal(t, "db new", (<-reader.Records()).Text)
+	assert.NoError(t, reader.Close())
+
+	// search returns matches with context of the same component
+	matches := env.SearchOutput(1, Containing("error"))
+	assert.Len(t, matches, 2)
+	assert.Equal(t, "api error 1", matches[0].Record.Text)
+	assert.Equal(t, []string{"api listening"}, texts(matches[0].Before))
+	assert.Equal(t, []string{"api error 2"}, texts(matches[0].After))
+	assert.Equal(t, "api error 2", matches[1].Record.Text)
+	assert.Equal(t, []string{"api error 1"}, texts(matches[1].Before))
+	assert.Equal(t, []string{"api request"}, texts(matches[1].After))
+
+	matches = env.SearchOutput(5, ForComponents("db"), FromLastLines(1))
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "db new", matches[0].Record.Text)
+	assert.Equal(t, []string{"db ready", "db query"}, texts(matches[0].Before))
+	assert.Empty(t, matches[0].After)
+
+	assert.Empty(t, env.SearchOutput(0, Containing("missing")))
+}
+
+func texts(records []OutputRecord) []string {
+	result := make([]string, 0, len(records))
+	for _, record := range records {
+		result = append(result, record.Text)
+	}
+	return result
+}
+
+func TestOutputLimits(t *testing.T) {
+	chatty := &mockComponent{}
+	quiet := &mockComponent{}
+	spillPath := filepath.Join(t.TempDir(), "spill.log")
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("chatty", chatty).AddComponent("quiet", quiet),
+		WithOutputLimits(OutputLimits{MaxLines: 4}),
+		WithComponentOutputLimits("chatty", OutputLimits{MaxLines: 2}),
+		WithOutputSpillFile(spillPath),
+	)
+	assert.NoError(t, err)
+
+	read := func(reader *Reader) []string {
+		var result []string
+		for {
+			select {
+			case msg := <-reader.Chan():
+				text := string(msg)
+				result = append(result, text[strings.Index(text, "<msg>")+5:len(text)-1])
+			case <-time.After(100 * time.Millisecond):
+				_ = reader.Close()
+				return result
+			}
+		}
+	}
+
+	startTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	quiet.w.WriteStringWithTime(startTime, "quiet 1")
+	chatty.w.WriteStringWithTime(startTime.Add(time.Second), "chatty 1")
+	chatty.w.WriteStringWithTime(startTime.Add(2*time.Second), "chatty 2")
+	chatty.w.WriteStringWithTime(startTime.Add(3*time.Second), "chatty 3")
+	quiet.w.WriteStringWithTime(startTime.Add(4*time.Second), "quiet 2")
+	quiet.w.WriteStringWithTime(startTime.Add(5*time.Second), "quiet 3")
+
+	// chatty is limited to two lines, and all output is limited to four lines
+	assert.Equal(t, []string{"chatty 2", "chatty 3", "quiet 2", "quiet 3"}, read(env.Output()))
+	assert.Equal(t, []string{"quiet 2", "quiet 3"}, read(env.Output(FromLastLines(2))))
+	assert.Equal(t, []string{"chatty 3", "quiet 2", "quiet 3"}, read(env.Output(FromTime(startTime.Add(3*time.Second)))))
+	assert.Equal(t, []string{"quiet 3"}, read(env.Output(FromLastLines(1), FromTime(startTime.Add(time.Second)))))
+
+	// evicted lines are buffered until the output is closed
+	assert.NoError(t, env.CloseOutput())
+	data, err := os.ReadFile(spillPath)
+	assert.NoError(t, err)
+	spilled := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, spilled, 2)
+	assert.Contains(t, spilled[0], "chatty 1")
+	assert.Contains(t, spilled[1], "quiet 1")
+
+	// the spill file is reopened once more lines are evicted
+	chatty.w.WriteStringWithTime(startTime.Add(6*time.Second), "chatty 4")
+	assert.NoError(t, env.CloseOutput())
+	data, err = os.ReadFile(spillPath)
+	assert.NoError(t, err)
+	spilled = strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, spilled, 3)
+	assert.Contains(t, spilled[2], "chatty 2")
+
+	// output limits of missing components are rejected
+	_, err = NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("chatty", &mockComponent{}),
+		WithComponentOutputLimits("missing", OutputLimits{MaxLines: 2}),
+	)
+	assert.Error(t, err)
+}
+
+// eagerComponent writes output as soon as it is attached to an environment, like a docker component
+// following the logs of a container that is already running.
+type eagerComponent struct {
+	*mockComponent
+	lines []string
+}
+
+func (e *eagerComponent) AttachEnvironment(ctx context.Context, env *Environment, w *Writer) error {
+	for _, line := range e.lines {
+		w.WriteString(line)
+	}
+	return e.mockComponent.AttachEnvironment(ctx, env, w)
+}
+
+func TestOutputLimitsApplyOnAttach(t *testing.T) {
+	component := &eagerComponent{mockComponent: &mockComponent{}, lines: []string{"eager 1", "eager 2", "eager 3"}}
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("eager", component),
+		WithComponentOutputLimits("eager", OutputLimits{MaxLines: 1}),
+	)
+	assert.NoError(t, err)
+
+	output, err := env.ComponentOutput("eager")
+	assert.NoError(t, err)
+	records := output.Records()
+	assert.Len(t, records, 1)
+	assert.Equal(t, "eager 3", records[0].Text)
+}
+
+func TestSlowOutputReaders(t *testing.T) {
+	component := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+
+	slow := env.Output()
+	disconnected := env.Output(DisconnectIfSlow())
+
+	// writing must never wait for readers that do not read
+	total := chanBufferSize + 11
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for i := 0; i < total; i++ {
+			component.w.WriteString(fmt.Sprintf("message %d", i))
+		}
+	}()
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writing output was blocked by slow readers")
+	}
+
+	// the slow reader receives what fits in its buffer, followed by a marker of the dropped lines
+	var slowMessages []string
+	drained := false
+	for !drained {
+		select {
+		case msg := <-slow.Chan():
+			slowMessages = append(slowMessages, string(msg))
+		case <-time.After(100 * time.Millisecond):
+			drained = true
+		}
+	}
+	component.w.WriteString("after catching up")
+	slowMessages = append(slowMessages, string(<-slow.Chan()), string(<-slow.Chan()))
+	assert.Contains(t, slowMessages[len(slowMessages)-1], "after catching up")
+	dropped := int(slow.Dropped())
+	assert.GreaterOrEqual(t, dropped, 10)
+	assert.Len(t, slowMessages, total-dropped+2)
+	assert.Contains(t, slowMessages[len(slowMessages)-2], "<component>component<time>")
+	assert.Contains(t, slowMessages[len(slowMessages)-2], fmt.Sprintf("%d lines dropped", dropped))
+
+	// the disconnected reader receives what fits in its buffer, and then its channel is closed
+	count := 0
+	for range disconnected.Chan() {
+		count++
+	}
+	assert.GreaterOrEqual(t, count, chanBufferSize)
+	assert.Equal(t, uint64(1), disconnected.Dropped())
+
+	assert.NoError(t, slow.Close())
+	assert.NoError(t, disconnected.Close())
+}
+
+func TestStartAndStopComponentWithDependencies(t *testing.T) {
+	component1 := &mockComponent{}
+	component2 := &mockComponent{}
+	component3 := &mockComponent{}
+	unrelated := &mockComponent{}
+
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().
+			AddComponent("component-1", component1).
+			AddComponent("component-2", component2, "component-1").
+			AddComponent("component-3", component3, "component-2").
+			AddComponent("unrelated", unrelated),
+	)
+	assert.NoError(t, err)
+
+	// Start without dependencies
+	started, err := env.StartComponent(context.Background(), "component-2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"component-2"}, started)
+	assert.False(t, component1.startCalled)
+
+	// Start with dependencies, skipping already running components
+	component2.initFlags()
+	started, err = env.StartComponent(context.Background(), "component-3", WithDependencies())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"component-1", "component-3"}, started)
+	assert.True(t, component1.prepareCalled)
+	assert.False(t, component2.startCalled)
+	assert.False(t, unrelated.startCalled)
+
+	// Stop with dependents
+	stopped, err := env.StopComponent(context.Background(), "component-1", WithDependents())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"component-3", "component-2", "component-1"}, stopped)
+	assert.Equal(t, ComponentStatusStopped, component3.status)
+	assert.False(t, unrelated.stopCalled)
+
+	// Unknown component
+	started, err = env.StartComponent(context.Background(), "not exist", WithDependencies())
+	assert.Error(t, err)
+	assert.Nil(t, started)
+}
+
+func TestFailurePolicies(t *testing.T) {
+	setup := func(policy FailurePolicy) (*Environment, map[string]*mockComponent) {
+		components := map[string]*mockComponent{
+			"a": {},
+			"b": {shouldFail: true},
+			"c": {},
+			"d": {},
+		}
+		env, err := NewEnvironment(
+			"test-env",
+			NewComponentGraph().
+				AddComponent("a", components["a"]).
+				AddComponent("b", components["b"], "a").
+				AddComponent("c", components["c"]).
+				AddComponent("d", components["d"], "b"),
+			WithFailurePolicy(policy),
+		)
+		assert.NoError(t, err)
+		return env, components
+	}
+	outcomes := func(err error) map[string]ComponentOutcome {
+		var applyErr *ApplyError
+		assert.ErrorAs(t, err, &applyErr)
+		result := make(map[string]ComponentOutcome)
+		for _, outcome := range applyErr.Components {
+			result[outcome.ID] = outcome
+		}
+		return result
+	}
+
+	// Fail fast
+	env, components := setup(FailurePolicyFailFast)
+	err := env.StartAll(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "start error")
+	result := outcomes(err)
+	assert.Len(t, result, 4)
+	assert.Equal(t, ComponentResultSucceeded, result["a"].Result)
+	assert.Equal(t, ComponentActionStart, result["b"].Action)
+	assert.Equal(t, ComponentResultFailed, result["b"].Result)
+	assert.Contains(t, result["b"].Error, "start error")
+	assert.Error(t, result["b"].Err())
+	assert.Equal(t, ComponentResultSkipped, result["d"].Result)
+	assert.Equal(t, ComponentStatusRunning, components["a"].status)
+
+	// Continue
+	env, components = setup(FailurePolicyContinue)
+	err = env.StartAll(context.Background())
+	assert.Error(t, err)
+	result = outcomes(err)
+	assert.Equal(t, ComponentResultSucceeded, result["a"].Result)
+	assert.Equal(t, ComponentResultFailed, result["b"].Result)
+	assert.Equal(t, ComponentResultSucceeded, result["c"].Result)
+	assert.Equal(t, ComponentResultSkipped, result["d"].Result)
+	assert.Equal(t, ComponentStatusRunning, components["c"].status)
+	assert.False(t, components["d"].startCalled)
+
+	var applyErr *ApplyError
+	assert.ErrorAs(t, err, &applyErr)
+	assert.Len(t, applyErr.Failed(), 1)
+	assert.Equal(t, FailurePolicyContinue, applyErr.Policy)
+
+	// Rollback
+	env, components = setup(FailurePolicyRollback)
+	err = env.Apply(context.Background(), []string{"a", "b", "c", "d"})
+	assert.Error(t, err)
+	result = outcomes(err)
+	assert.Equal(t, ComponentResultRolledBack, result["a"].Result)
+	assert.Equal(t, ComponentResultFailed, result["b"].Result)
+	assert.Equal(t, ComponentStatusStopped, components["a"].status)
+	assert.NotEqual(t, ComponentStatusRunning, components["c"].status)
+	assert.False(t, components["d"].startCalled)
+
+	// No failure
+	env, components = setup(FailurePolicyRollback)
+	components["b"].shouldFail = false
+	err = env.Apply(context.Background(), []string{"a", "c"})
+	assert.NoError(t, err)
+}
+
+func TestPlan(t *testing.T) {
+	db := &mockComponent{status: ComponentStatusStopped}
+	service := &mockComponent{status: ComponentStatusStopped}
+	worker := &mockComponent{status: ComponentStatusStopped}
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().
+			AddComponent("db", db).
+			AddComponent("service", service, "db").
+			AddComponent("worker", worker, "db"),
+	)
+	assert.NoError(t, err)
+
+	plan, err := env.Plan(context.Background(), []string{"db", "service"})
+	assert.NoError(t, err)
+	assert.Equal(t, Plan{Components: []PlannedComponent{
+		{ID: "db", Status: ComponentStatusStopped, Actions: []ComponentAction{ComponentActionPrepare, ComponentActionStart}},
+		{ID: "service", Status: ComponentStatusStopped, Actions: []ComponentAction{ComponentActionPrepare, ComponentActionStart}},
+		{ID: "worker", Status: ComponentStatusStopped, Actions: []ComponentAction{}},
+	}}, plan)
+	assert.Len(t, plan.Changes(), 2)
+	assert.False(t, db.prepareCalled)
+	assert.False(t, db.startCalled)
+
+	err = env.Apply(context.Background(), []string{"db", "service"})
+	assert.NoError(t, err)
+	plan, err = env.Plan(context.Background(), []string{"db", "worker"})
+	assert.NoError(t, err)
+	assert.Equal(t, Plan{Components: []PlannedComponent{
+		{ID: "db", Status: ComponentStatusRunning, Actions: []ComponentAction{}},
+		{ID: "service", Status: ComponentStatusRunning, Actions: []ComponentAction{ComponentActionStop}},
+		{ID: "worker", Status: ComponentStatusStopped, Actions: []ComponentAction{ComponentActionPrepare, ComponentActionStart}},
+	}}, plan)
+	assert.Equal(t, ComponentStatusRunning, service.status)
+	assert.False(t, worker.startCalled)
+}
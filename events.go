@@ -0,0 +1,159 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType represents the type of lifecycle event published by the Environment.
+type EventType string
+
+const (
+	// EventComponentPrepared is published when a component finished preparing successfully.
+	EventComponentPrepared EventType = "component_prepared"
+
+	// EventComponentStarting is published right before a component is started.
+	EventComponentStarting EventType = "component_starting"
+
+	// EventComponentRunning is published when a component finished starting successfully.
+	EventComponentRunning EventType = "component_running"
+
+	// EventComponentRestarting is published right before a running component is restarted in place.
+	// Once restarted, EventComponentRunning is published.
+	EventComponentRestarting EventType = "component_restarting"
+
+	// EventComponentFailed is published when preparing, starting, stopping or cleaning up a component fails.
+	EventComponentFailed EventType = "component_failed"
+
+	// EventComponentStopped is published when a component finished stopping successfully.
+	EventComponentStopped EventType = "component_stopped"
+
+	// EventComponentCleaned is published when a component finished cleaning up successfully.
+	EventComponentCleaned EventType = "component_cleaned"
+
+	// EventCascadePlanned is published after a component changed, before restarting the components that depend on it.
+	// Components lists the components that are about to be restarted, in order.
+	EventCascadePlanned EventType = "cascade_planned"
+
+	// EventOperationStarted is published when an operation on the environment begins, e.g. applying state.
+	EventOperationStarted EventType = "operation_started"
+
+	// EventOperationFinished is published when an operation on the environment finishes, whether it failed or not.
+	EventOperationFinished EventType = "operation_finished"
+)
+
+// Operation represents an operation performed on the Environment.
+type Operation string
+
+const (
+	// OperationApply is performed by Environment.Apply.
+	OperationApply Operation = "apply"
+
+	// OperationStartAll is performed by Environment.StartAll.
+	OperationStartAll Operation = "start_all"
+
+	// OperationStopAll is performed by Environment.StopAll.
+	OperationStopAll Operation = "stop_all"
+
+	// OperationStartComponent is performed by Environment.StartComponent.
+	OperationStartComponent Operation = "start_component"
+
+	// OperationStopComponent is performed by Environment.StopComponent.
+	OperationStopComponent Operation = "stop_component"
+
+	// OperationRestartAll is performed by Environment.RestartAll.
+	OperationRestartAll Operation = "restart_all"
+
+	// OperationRestartComponent is performed by Environment.RestartComponent.
+	OperationRestartComponent Operation = "restart_component"
+
+	// OperationCleanup is performed by Environment.Cleanup.
+	OperationCleanup Operation = "cleanup"
+)
+
+// Event describes a single lifecycle event of the Environment.
+//
+// Fields:
+// - Type: The type of the event.
+// - Time: The time the event occurred.
+// - Operation: The operation the event is part of.
+// - ComponentID: The component the event refers to, or for operation events, the component the operation targets.
+// - Duration: For events that mark the end of an action, how long the action took, in nanoseconds when encoded as JSON.
+// - Error: For failure events and failed operations, the error that occurred.
+// - Components: For cascade events, the IDs of the components that are about to be restarted.
+type Event struct {
+	Type        EventType     `json:"type"`
+	Time        time.Time     `json:"time"`
+	Operation   Operation     `json:"operation,omitempty"`
+	ComponentID string        `json:"component_id,omitempty"`
+	Duration    time.Duration `json:"duration,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	Components  []string      `json:"components,omitempty"`
+}
+
+// eventBus distributes lifecycle events to all current subscribers.
+type eventBus struct {
+	lock        sync.Mutex
+	subscribers []*Subscription
+}
+
+// newEventBus creates a new instance of eventBus with no subscribers.
+func newEventBus() *eventBus {
+	return &eventBus{}
+}
+
+// publish sends event to all subscribers. Publishing never blocks: subscribers that do not keep up
+// with the events miss the ones that do not fit in their buffer.
+func (e *eventBus) publish(event Event) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, subscription := range e.subscribers {
+		select {
+		case subscription.ch <- event:
+		default:
+		}
+	}
+}
+
+// subscribe creates and returns a new Subscription receiving all events published from now on.
+func (e *eventBus) subscribe() *Subscription {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	subscription := &Subscription{ch: make(chan Event, chanBufferSize)}
+	e.subscribers = append(e.subscribers, subscription)
+	subscription.close = func() {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+		for i, current := range e.subscribers {
+			if current == subscription {
+				e.subscribers = append(e.subscribers[:i], e.subscribers[i+1:]...)
+				close(subscription.ch)
+				return
+			}
+		}
+	}
+
+	return subscription
+}
+
+// Subscription represents a subscription to the lifecycle events of an Environment.
+type Subscription struct {
+	ch    chan Event
+	close func()
+}
+
+// Chan returns the channel for receiving events. The channel is closed when the subscription is closed.
+func (s *Subscription) Chan() <-chan Event {
+	return s.ch
+}
+
+// Close stops the subscription from receiving any more events.
+func (s *Subscription) Close() error {
+	s.close()
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	db := &mockComponent{}
+	service := &mockComponent{}
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().
+			AddComponent("db", db).
+			AddComponent("service", service, "db"),
+	)
+	assert.NoError(t, err)
+
+	subscription := env.Subscribe()
+	receive := func() []Event {
+		var result []Event
+		for {
+			select {
+			case event := <-subscription.Chan():
+				result = append(result, event)
+			default:
+				return result
+			}
+		}
+	}
+	types := func(events []Event) []string {
+		result := make([]string, 0, len(events))
+		for _, event := range events {
+			result = append(result, string(event.Type)+":"+event.ComponentID)
+		}
+		return result
+	}
+
+	err = env.StartAll(context.Background())
+	assert.NoError(t, err)
+	events := receive()
+	// components are prepared concurrently
+	assert.ElementsMatch(t, []string{"component_prepared:db", "component_prepared:service"}, types(events[1:3]))
+	assert.Equal(t, []string{
+		"operation_started:",
+		"component_starting:db",
+		"component_running:db",
+		"component_starting:service",
+		"component_running:service",
+		"operation_finished:",
+	}, types(append(events[:1:1], events[3:]...)))
+	assert.Equal(t, OperationStartAll, events[0].Operation)
+	assert.Equal(t, OperationStartAll, events[len(events)-1].Operation)
+	assert.Empty(t, events[len(events)-1].Error)
+	for _, event := range events {
+		assert.False(t, event.Time.IsZero())
+	}
+
+	_, err = env.StopComponent(context.Background(), "db", WithDependents())
+	assert.NoError(t, err)
+	events = receive()
+	assert.Equal(t, []string{
+		"operation_started:db",
+		"component_stopped:service",
+		"component_stopped:db",
+		"operation_finished:db",
+	}, types(events))
+	assert.Equal(t, OperationStopComponent, events[0].Operation)
+
+	service.shouldFail = true
+	_, err = env.StartComponent(context.Background(), "service")
+	assert.Error(t, err)
+	events = receive()
+	assert.Equal(t, []string{
+		"operation_started:service",
+		"component_prepared:service",
+		"component_starting:service",
+		"component_failed:service",
+		"operation_finished:service",
+	}, types(events))
+	assert.Contains(t, events[3].Error, "start error")
+	assert.Contains(t, events[4].Error, "start error")
+
+	service.shouldFail = false
+	err = env.Cleanup(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"operation_started:",
+		"component_cleaned:service",
+		"component_cleaned:db",
+		"operation_finished:",
+	}, types(receive()))
+
+	err = subscription.Close()
+	assert.NoError(t, err)
+	_, ok := <-subscription.Chan()
+	assert.False(t, ok)
+
+	// closed subscriptions no longer receive events
+	err = env.StartAll(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestAPIEvents(t *testing.T) {
+	component := &mockComponent{}
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("component", component),
+	)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(getEventsHandler{env: env})
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	assert.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, textEventStream, res.Header.Get(contentType))
+
+	_, err = env.StartComponent(context.Background(), "component")
+	assert.NoError(t, err)
+
+	var events []Event
+	scanner := bufio.NewScanner(res.Body)
+	for len(events) < 5 && scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var event Event
+		err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event)
+		assert.NoError(t, err)
+		events = append(events, event)
+	}
+	assert.Len(t, events, 5)
+	assert.Equal(t, EventOperationStarted, events[0].Type)
+	assert.Equal(t, EventComponentRunning, events[3].Type)
+	assert.Equal(t, "component", events[3].ComponentID)
+	assert.Equal(t, EventOperationFinished, events[4].Type)
+}
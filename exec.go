@@ -8,6 +8,12 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 )
 
 // ExecutionMode represents different execution modes for ENVITE.
@@ -23,7 +29,17 @@ const (
 	// performs cleanup, and then exits.
 	ExecutionModeStop ExecutionMode = "stop"
 
+	// ExecutionModeRestart indicates the restart execution mode, which restarts all components in the environment,
+	// starting the ones that are not running, and then exits.
+	ExecutionModeRestart ExecutionMode = "restart"
+
+	// ExecutionModePlan indicates the plan execution mode, which prints the actions the start execution mode would
+	// take on each component, without changing anything, and then exits.
+	ExecutionModePlan ExecutionMode = "plan"
+
 	// ExecutionModeDaemon indicates the daemon execution mode, which starts ENVITE as a daemon and serving a web UI.
+	// On an interrupt or termination signal, the daemon shuts down and applies its exit policy, see WithExitPolicy.
+	// Another signal while shutting down forces it to quit immediately.
 	ExecutionModeDaemon ExecutionMode = "daemon"
 )
 
@@ -32,6 +48,8 @@ func DescribeAvailableModes() string {
 	return fmt.Sprintf("available modes:\n" +
 		"start - start all components in the environment and exit\n" +
 		"stop - stop all components in the environment and exit\n" +
+		"restart - restart all components in the environment and exit\n" +
+		"plan - print the actions start would take on each component and exit\n" +
 		"daemon - start ENVITE as a daemon and serve via a web UI\n")
 }
 
@@ -43,33 +61,127 @@ func ParseExecutionMode(value string) (ExecutionMode, error) {
 		return ExecutionModeStart, nil
 	case "stop":
 		return ExecutionModeStop, nil
+	case "restart":
+		return ExecutionModeRestart, nil
+	case "plan":
+		return ExecutionModePlan, nil
 	case "daemon", "":
 		return ExecutionModeDaemon, nil
 	}
 	return "", ErrInvalidExecutionMode{v: value}
 }
 
+// ExecuteOption is a function type for configuring how Execute runs.
+type ExecuteOption func(*executeOptions)
+
+// executeOptions holds the configuration of Execute.
+type executeOptions struct {
+	streaming *OutputStreaming
+}
+
+// WithOutputStreaming is an ExecuteOption that makes the start, stop and restart execution modes write the output
+// of components while they run, see OutputStreaming.
+func WithOutputStreaming(config OutputStreaming) ExecuteOption {
+	return func(o *executeOptions) {
+		o.streaming = &config
+	}
+}
+
+// run runs operation on env, streaming the output of components while it runs if configured to.
+// The output files of env are closed once operation is done, see Environment.CloseOutput.
+func (o executeOptions) run(env *Environment, operation func() error) error {
+	var err error
+	if o.streaming == nil || o.streaming.Writer == nil {
+		err = operation()
+	} else {
+		err = newOutputStream(env, *o.streaming).run(env, operation)
+	}
+
+	closeErr := env.CloseOutput()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
 // Execute performs the specified action based on the provided execution mode.
 // It takes a Server instance and an ExecutionMode as parameters and executes the corresponding action.
-// The available execution modes are ExecutionModeStart, ExecutionModeStop, and ExecutionModeDaemon.
-func Execute(server *Server, executionMode ExecutionMode) error {
+// The available execution modes are ExecutionModeStart, ExecutionModeStop, ExecutionModeRestart, ExecutionModePlan,
+// and ExecutionModeDaemon. See ExecuteOption to configure how modes run.
+func Execute(server *Server, executionMode ExecutionMode, options ...ExecuteOption) error {
+	opts := executeOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
 	switch executionMode {
 	case ExecutionModeStart:
-		return server.env.StartAll(context.Background())
+		return opts.run(server.env, func() error {
+			return server.env.StartAll(context.Background())
+		})
 	case ExecutionModeStop:
-		err := server.env.StopAll(context.Background())
+		return opts.run(server.env, func() error {
+			err := server.env.StopAll(context.Background())
+			if err != nil {
+				return err
+			}
+
+			return server.env.Cleanup(context.Background())
+		})
+	case ExecutionModeRestart:
+		return opts.run(server.env, func() error {
+			return server.env.RestartAll(context.Background())
+		})
+	case ExecutionModePlan:
+		plan, err := server.env.Plan(context.Background(), server.env.graph.ids)
 		if err != nil {
 			return err
 		}
 
-		return server.env.Cleanup(context.Background())
+		return writePlan(os.Stdout, plan)
 	case ExecutionModeDaemon:
-		fmt.Printf("%s\nstarting ENVITE daemon for %s at http://localhost%s\n", asciiArt, server.env.id, server.addr)
-		return server.Start()
+		fmt.Printf("%s\nstarting ENVITE daemon for %s at %s\n", asciiArt, server.env.id, server.URL())
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+		return server.serve(os.Stdout, signals, func() {
+			os.Exit(1)
+		})
 	}
 	return ErrInvalidExecutionMode{v: string(executionMode)}
 }
 
+// writePlan writes a human-readable table of plan to w, followed by a summary of the planned changes.
+func writePlan(w io.Writer, plan Plan) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, err := fmt.Fprintln(tw, "COMPONENT\tSTATUS\tACTIONS")
+	if err != nil {
+		return err
+	}
+
+	for _, c := range plan.Components {
+		actions := make([]string, 0, len(c.Actions))
+		for _, action := range c.Actions {
+			actions = append(actions, string(action))
+		}
+		if len(actions) == 0 {
+			actions = append(actions, "no-op")
+		}
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%s\n", c.ID, c.Status, strings.Join(actions, ", "))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "\n%d of %d components would change\n", len(plan.Changes()), len(plan.Components))
+	return err
+}
+
 //go:embed ascii.txt
 var asciiArt string
 
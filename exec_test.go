@@ -1,6 +1,7 @@
 package envite
 
 import (
+	"bytes"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -14,6 +15,14 @@ func TestParseExecutionMode(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, ExecutionModeStop, mode)
 
+	mode, err = ParseExecutionMode("restart")
+	assert.NoError(t, err)
+	assert.Equal(t, ExecutionModeRestart, mode)
+
+	mode, err = ParseExecutionMode("plan")
+	assert.NoError(t, err)
+	assert.Equal(t, ExecutionModePlan, mode)
+
 	mode, err = ParseExecutionMode("daemon")
 	assert.NoError(t, err)
 	assert.Equal(t, ExecutionModeDaemon, mode)
@@ -53,11 +62,113 @@ func TestExecute(t *testing.T) {
 	assert.True(t, component.startCalled)
 	assert.True(t, component.stopCalled)
 	assert.True(t, component.cleanupCalled)
+
+	component.startCalled = false
+	err = Execute(server, ExecutionModeRestart)
+	assert.NoError(t, err)
+	assert.Equal(t, ComponentStatusRunning, component.status)
+	assert.True(t, component.startCalled)
 }
 
 func TestDescribeAvailableModes(t *testing.T) {
 	result := DescribeAvailableModes()
 	assert.Contains(t, result, ExecutionModeStart, "missing start mode")
 	assert.Contains(t, result, ExecutionModeStop, "missing stop mode")
+	assert.Contains(t, result, ExecutionModeRestart, "missing restart mode")
+	assert.Contains(t, result, ExecutionModePlan, "missing plan mode")
 	assert.Contains(t, result, ExecutionModeDaemon, "missing daemon mode")
 }
+
+func TestWritePlan(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := writePlan(buf, Plan{Components: []PlannedComponent{
+		{ID: "db", Status: ComponentStatusRunning, Actions: []ComponentAction{}},
+		{ID: "service", Status: ComponentStatusStopped, Actions: []ComponentAction{ComponentActionPrepare, ComponentActionStart}},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "COMPONENT  STATUS   ACTIONS\n"+
+		"db         running  no-op\n"+
+		"service    stopped  prepare, start\n"+
+		"\n"+
+		"1 of 2 components would change\n", buf.String())
+}
+
+func TestExecuteOutputStreaming(t *testing.T) {
+	api := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("api", api))
+	assert.NoError(t, err)
+	api.onStart = func() {
+		api.w.Info("ready")
+		api.w.Debug("details")
+		api.w.WriteRecord(OutputRecord{Stream: OutputStreamStderr, Level: LogLevelInfo, Text: "warning"})
+	}
+
+	buf := &bytes.Buffer{}
+	err = Execute(NewServer("8080", env), ExecutionModeStart, WithOutputStreaming(OutputStreaming{
+		Writer:    buf,
+		Rendering: OutputRenderingANSI,
+		MinLevel:  LogLevelInfo,
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "\u001B[1;36mapi\u001B[22;39m | ready\n"+
+		"\u001B[1;36mapi\u001B[22;39m | \u001B[31mwarning\u001B[39m\n", buf.String())
+
+	buf.Reset()
+	err = Execute(NewServer("8080", env), ExecutionModeStop, WithOutputStreaming(OutputStreaming{
+		Writer:     buf,
+		Components: []string{"other"},
+	}))
+	assert.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestExecuteOutputStreamingTailOnFailure(t *testing.T) {
+	api := &mockComponent{}
+	db := &mockComponent{shouldFail: true}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("db", db).AddComponent("api", api, "db"))
+	assert.NoError(t, err)
+	db.w.Info("starting")
+	db.w.Error("connection refused")
+	db.w.Debug("retrying")
+
+	buf := &bytes.Buffer{}
+	err = Execute(NewServer("8080", env), ExecutionModeStart, WithOutputStreaming(OutputStreaming{
+		Writer:        buf,
+		MinLevel:      LogLevelError,
+		TailOnFailure: 2,
+	}))
+	assert.Error(t, err)
+	assert.Equal(t, "db  | connection refused\n"+
+		"last 2 lines of output of db:\n"+
+		"db  | connection refused\n"+
+		"db  | retrying\n", buf.String())
+
+	buf.Reset()
+	err = Execute(NewServer("8080", env), ExecutionModeStart, WithOutputStreaming(OutputStreaming{
+		Writer:        buf,
+		Quiet:         true,
+		TailOnFailure: 1,
+	}))
+	assert.Error(t, err)
+	assert.Equal(t, "last 1 lines of output of db:\ndb  | retrying\n", buf.String())
+}
+
+func TestExecuteOutputStreamingTailOnFailureStop(t *testing.T) {
+	api := &mockComponent{status: ComponentStatusRunning}
+	db := &mockComponent{status: ComponentStatusRunning, shouldFail: true}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("db", db).AddComponent("api", api, "db"))
+	assert.NoError(t, err)
+	api.w.Info("stopping")
+	db.w.Info("stopping")
+	db.w.Error("shutdown timed out")
+
+	buf := &bytes.Buffer{}
+	err = Execute(NewServer("8080", env), ExecutionModeStop, WithOutputStreaming(OutputStreaming{
+		Writer:        buf,
+		Quiet:         true,
+		TailOnFailure: 1,
+	}))
+	assert.ErrorContains(t, err, "stop error")
+	assert.True(t, api.stopCalled)
+	assert.Equal(t, "last 1 lines of output of db:\ndb  | shutdown timed out\n", buf.String())
+}
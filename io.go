@@ -5,65 +5,233 @@
 package envite
 
 import (
+	"bufio"
+	"container/list"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	chanBufferSize = 100
-	timeFormat     = "2006-01-02T15:04:05.000000000Z07:00"
+	chanBufferSize   = 100
+	timeFormat       = "2006-01-02T15:04:05.000000000Z07:00"
+	droppedAttribute = "envite.dropped"
+	spillBufferSize  = 64 * 1024
 )
 
 // outputManager is responsible for managing and distributing log output messages.
+// Messages are kept in memory within the configured limits, see WithOutputLimits and WithComponentOutputLimits.
+// Once a limit is exceeded, the oldest messages are evicted, and optionally appended to a spill file.
+// Messages may also be mirrored to log files, see WithLogFiles. Both are opened when first written to,
+// and are closed by close.
 type outputManager struct {
-	lock     sync.Mutex
-	messages [][]byte
-	readers  []*Reader
+	lock            sync.Mutex
+	entries         *list.List
+	lines           int
+	bytes           int
+	components      map[string]*componentOutput
+	limits          OutputLimits
+	componentLimits map[string]OutputLimits
+	spillPath       string
+	spill           *os.File
+	spillBuffer     *bufio.Writer
+	files           *logFiles
+	alerts          *componentAlerts
+	logger          Logger
+	readers         []*Reader
+}
+
+// outputEntry is a single message kept by the outputManager, along with its size in the legacy format.
+type outputEntry struct {
+	record OutputRecord
+	size   int
+}
+
+// componentOutput tracks the messages of a single component kept by the outputManager, from oldest to newest.
+type componentOutput struct {
+	elements []*list.Element
+	bytes    int
 }
 
 // newOutputManager creates a new instance of outputManager.
 func newOutputManager() *outputManager {
-	return &outputManager{}
+	return &outputManager{
+		entries:         list.New(),
+		components:      make(map[string]*componentOutput),
+		componentLimits: make(map[string]OutputLimits),
+	}
 }
 
-// write logs a message with the given timestamp, component, and message content.
-func (o *outputManager) write(t time.Time, component, message string) {
-	data := []byte(fmt.Sprintf("<component>%s<time>%s<msg>%s\n", component, t.Local().Format(timeFormat), message))
+// write logs a record.
+// Writing never blocks on readers: readers that do not keep up either miss messages or are disconnected,
+// see Reader for details.
+func (o *outputManager) write(record OutputRecord) {
 	o.lock.Lock()
 	defer o.lock.Unlock()
-	o.messages = append(o.messages, data)
+	if o.alerts != nil {
+		o.alerts.evaluate(&record)
+	}
+	entry := &outputEntry{record: record, size: len(record.legacy(OutputRenderingANSI))}
+	o.store(entry)
+	if o.files != nil {
+		err := o.files.write(record)
+		if err != nil {
+			// log files are best effort, so they are disabled after the first failure rather than failing writers
+			if o.logger != nil {
+				o.logger(LogLevelError, fmt.Sprintf("could not write log files, disabling log files: %v", err))
+			}
+			o.files = nil
+		}
+	}
+	readers := o.readers[:0]
 	for _, reader := range o.readers {
-		reader.ch <- data
+		if reader.send(entry) {
+			readers = append(readers, reader)
+		}
 	}
+	for i := len(readers); i < len(o.readers); i++ {
+		o.readers[i] = nil
+	}
+	o.readers = readers
 }
 
-// reader creates and returns a new Reader instance to read log messages.
-func (o *outputManager) reader() *Reader {
+// store keeps entry in memory, evicting the oldest entries of its component and then of all components
+// until both are within their limits. It must be called while holding the lock.
+func (o *outputManager) store(entry *outputEntry) {
+	c, ok := o.components[entry.record.ComponentID]
+	if !ok {
+		c = &componentOutput{}
+		o.components[entry.record.ComponentID] = c
+	}
+	c.elements = append(c.elements, o.entries.PushBack(entry))
+	c.bytes += entry.size
+	o.lines++
+	o.bytes += entry.size
+
+	componentLimits := o.componentLimits[entry.record.ComponentID]
+	for len(c.elements) > 1 && componentLimits.exceeded(len(c.elements), c.bytes) {
+		o.evict(c.elements[0])
+	}
+	for o.entries.Len() > 1 && o.limits.exceeded(o.lines, o.bytes) {
+		o.evict(o.entries.Front())
+	}
+}
+
+// evict removes the oldest entry of a component from memory, spilling it to the spill file if one is configured.
+// Spilled entries are buffered, so that writers do not wait for the file on every eviction.
+// It must be called while holding the lock.
+func (o *outputManager) evict(element *list.Element) {
+	entry := o.entries.Remove(element).(*outputEntry)
+	c := o.components[entry.record.ComponentID]
+	c.elements[0] = nil
+	c.elements = c.elements[1:]
+	c.bytes -= entry.size
+	o.lines--
+	o.bytes -= entry.size
+
+	if o.spillPath == "" {
+		return
+	}
+
+	var err error
+	if o.spill == nil {
+		o.spill, err = os.OpenFile(o.spillPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err == nil {
+			o.spillBuffer = bufio.NewWriterSize(o.spill, spillBufferSize)
+		}
+	}
+	if err == nil {
+		_, err = o.spillBuffer.Write(entry.record.legacy(OutputRenderingPlain))
+	}
+	if err != nil {
+		// spilling is best effort, so it is disabled after the first failure rather than failing writers
+		if o.logger != nil {
+			o.logger(LogLevelError, fmt.Sprintf("could not spill output to %s, disabling spilling: %v", o.spillPath, err))
+		}
+		o.spillPath = ""
+		if o.spill != nil {
+			_ = o.spill.Close()
+			o.spill = nil
+			o.spillBuffer = nil
+		}
+	}
+}
+
+// close flushes the spill file and closes it along with the log files. They are opened again if written to afterward.
+func (o *outputManager) close() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	var errs []error
+	if o.spill != nil {
+		err := o.spillBuffer.Flush()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not flush spill file %s: %w", o.spillPath, err))
+		}
+		err = o.spill.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not close spill file %s: %w", o.spillPath, err))
+		}
+		o.spill = nil
+		o.spillBuffer = nil
+	}
+	if o.files != nil {
+		errs = append(errs, o.files.close())
+	}
+	return errors.Join(errs...)
+}
+
+// logFiles returns the log files output is mirrored to, or nil if there are none.
+func (o *outputManager) logFiles() *logFiles {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.files
+}
+
+// setAlerts makes the outputManager evaluate alerts on every record written from now on.
+func (o *outputManager) setAlerts(alerts *componentAlerts) {
 	o.lock.Lock()
 	defer o.lock.Unlock()
+	o.alerts = alerts
+}
+
+// reader creates and returns a new Reader instance to read log messages.
+// The reader first receives the messages kept in memory that match options, and then all new messages that match.
+func (o *outputManager) reader(options ...ReaderOption) *Reader {
+	opts := newReaderOptions(options)
 
-	ch := make(chan []byte, chanBufferSize)
-	messages := o.messages
+	o.lock.Lock()
+	defer o.lock.Unlock()
 
-	go func() {
-		for _, message := range messages {
-			ch <- message
+	var messages []OutputRecord
+	for element := o.entries.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*outputEntry)
+		if opts.matches(entry.record) {
+			messages = append(messages, entry.record)
 		}
-	}()
+	}
+	if opts.lastLines > 0 && len(messages) > opts.lastLines {
+		messages = messages[len(messages)-opts.lastLines:]
+	}
 
-	reader := &Reader{ch: ch}
+	reader := newReader(messages, opts)
 	o.readers = append(o.readers, reader)
 	reader.close = func() {
 		o.lock.Lock()
-		defer o.lock.Unlock()
 		for i, current := range o.readers {
 			if current == reader {
 				o.readers = append(o.readers[:i], o.readers[i+1:]...)
-				return
+				break
 			}
 		}
+		o.lock.Unlock()
+		reader.shutdown()
 	}
 
 	return reader
@@ -77,20 +245,344 @@ func (o *outputManager) writer(component string) *Writer {
 	}
 }
 
+// OutputLimits bounds the output kept in memory by the Environment. Zero values mean no limit.
+type OutputLimits struct {
+	// MaxLines is the maximum number of lines kept.
+	MaxLines int
+
+	// MaxBytes is the maximum total size of the lines kept, in bytes. The most recent line is always kept,
+	// even if it is larger.
+	MaxBytes int
+}
+
+// exceeded reports whether the given number of lines and bytes exceeds the limits.
+func (l OutputLimits) exceeded(lines, bytes int) bool {
+	return (l.MaxLines > 0 && lines > l.MaxLines) || (l.MaxBytes > 0 && bytes > l.MaxBytes)
+}
+
+// ReaderOption is a function type for configuring which output a new Reader receives.
+// By default, a Reader receives all output kept in memory, and then all new output.
+// Options filtering output, such as ForComponents or Containing, apply to both, and can be combined.
+type ReaderOption func(*readerOptions)
+
+// readerOptions holds the configuration of a new Reader.
+type readerOptions struct {
+	lastLines        int
+	since            time.Time
+	until            time.Time
+	components       map[string]bool
+	streams          map[OutputStream]bool
+	contains         string
+	pattern          *regexp.Regexp
+	matcher          LineMatcher
+	minLevel         LogLevel
+	alerting         bool
+	disconnectIfSlow bool
+}
+
+// newReaderOptions applies options to the default configuration of a Reader.
+func newReaderOptions(options []ReaderOption) readerOptions {
+	opts := readerOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts
+}
+
+// matches reports whether record passes all the filters of the options.
+func (o readerOptions) matches(record OutputRecord) bool {
+	if !o.since.IsZero() && record.Time.Before(o.since) {
+		return false
+	}
+	if !o.until.IsZero() && record.Time.After(o.until) {
+		return false
+	}
+	if o.components != nil && !o.components[record.ComponentID] {
+		return false
+	}
+	if o.streams != nil && !o.streams[record.Stream] {
+		return false
+	}
+	if o.contains != "" && !strings.Contains(record.Text, o.contains) {
+		return false
+	}
+	if o.pattern != nil && !o.pattern.MatchString(record.Text) {
+		return false
+	}
+	if o.matcher != nil && !o.matcher.Match(record.Text) {
+		return false
+	}
+	if record.Level < o.minLevel {
+		return false
+	}
+	if o.alerting && record.Alert == "" {
+		return false
+	}
+	return true
+}
+
+// FromLastLines is a ReaderOption that makes a Reader start from the last n lines of output kept in memory.
+func FromLastLines(n int) ReaderOption {
+	return func(o *readerOptions) {
+		o.lastLines = n
+	}
+}
+
+// FromTime is a ReaderOption that makes a Reader start from the output written at or after t.
+func FromTime(t time.Time) ReaderOption {
+	return func(o *readerOptions) {
+		o.since = t
+	}
+}
+
+// UntilTime is a ReaderOption that makes a Reader receive only output written at or before t.
+// Combined with FromTime, it selects a time range.
+func UntilTime(t time.Time) ReaderOption {
+	return func(o *readerOptions) {
+		o.until = t
+	}
+}
+
+// ForComponents is a ReaderOption that makes a Reader receive only the output of the given components.
+// It may be used more than once to add components.
+func ForComponents(ids ...string) ReaderOption {
+	return func(o *readerOptions) {
+		if o.components == nil {
+			o.components = make(map[string]bool, len(ids))
+		}
+		for _, id := range ids {
+			o.components[id] = true
+		}
+	}
+}
+
+// ForStreams is a ReaderOption that makes a Reader receive only the output written to the given streams.
+// It may be used more than once to add streams.
+func ForStreams(streams ...OutputStream) ReaderOption {
+	return func(o *readerOptions) {
+		if o.streams == nil {
+			o.streams = make(map[OutputStream]bool, len(streams))
+		}
+		for _, stream := range streams {
+			o.streams[stream] = true
+		}
+	}
+}
+
+// Containing is a ReaderOption that makes a Reader receive only lines of output whose text contains substring.
+func Containing(substring string) ReaderOption {
+	return func(o *readerOptions) {
+		o.contains = substring
+	}
+}
+
+// Matching is a ReaderOption that makes a Reader receive only lines of output whose text matches pattern.
+func Matching(pattern *regexp.Regexp) ReaderOption {
+	return func(o *readerOptions) {
+		o.pattern = pattern
+	}
+}
+
+// AtLevel is a ReaderOption that makes a Reader receive only lines of output at the given level or above.
+func AtLevel(level LogLevel) ReaderOption {
+	return func(o *readerOptions) {
+		o.minLevel = level
+	}
+}
+
+// Alerting is a ReaderOption that makes a Reader receive only lines of output that raised an alert,
+// see WithAlertRules.
+func Alerting() ReaderOption {
+	return func(o *readerOptions) {
+		o.alerting = true
+	}
+}
+
+// DisconnectIfSlow is a ReaderOption that makes a Reader get disconnected once it falls too far behind, instead
+// of missing messages. Once disconnected, the channel of the Reader is closed after the messages it already
+// received are consumed.
+func DisconnectIfSlow() ReaderOption {
+	return func(o *readerOptions) {
+		o.disconnectIfSlow = true
+	}
+}
+
 // Reader represents a reader for log messages.
+//
+// Writers never wait for readers. A Reader buffers up to chanBufferSize new messages that were not consumed yet,
+// on top of the messages kept in memory it replays when created. Once the buffer is full, new messages are dropped,
+// and a marker message stating how many messages were dropped is delivered for each affected component as soon as
+// there is room again. Alternatively, see DisconnectIfSlow.
 type Reader struct {
-	ch    chan []byte
-	close func()
+	records    chan OutputRecord
+	legacy     chan []byte
+	legacyOnce sync.Once
+	close      func()
+	options    readerOptions
+	dropped    atomic.Uint64
+
+	lock      sync.Mutex
+	cond      *sync.Cond
+	queue     []OutputRecord
+	replaying int
+	skipped   map[string]int
+	closed    bool
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newReader creates a new Reader that first receives the given messages, and starts delivering them.
+// Any new message is filtered according to options.
+func newReader(messages []OutputRecord, options readerOptions) *Reader {
+	r := &Reader{
+		records:   make(chan OutputRecord),
+		options:   options,
+		queue:     messages,
+		replaying: len(messages),
+		skipped:   make(map[string]int),
+		done:      make(chan struct{}),
+	}
+	r.cond = sync.NewCond(&r.lock)
+	go r.deliver()
+	return r
+}
+
+// send queues the record of entry for delivery without blocking, unless it is filtered out. It returns false
+// if the reader should no longer receive messages, either since it is closed or since it was disconnected for being
+// too slow.
+func (r *Reader) send(entry *outputEntry) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return false
+	}
+	if !r.options.matches(entry.record) {
+		return true
+	}
+
+	if len(r.queue)-r.replaying >= chanBufferSize {
+		r.dropped.Add(1)
+		if r.options.disconnectIfSlow {
+			r.closed = true
+			r.cond.Signal()
+			return false
+		}
+		r.skipped[entry.record.ComponentID]++
+		return true
+	}
+
+	if len(r.skipped) > 0 {
+		components := make([]string, 0, len(r.skipped))
+		for component := range r.skipped {
+			components = append(components, component)
+		}
+		sort.Strings(components)
+		now := time.Now()
+		for _, component := range components {
+			text := fmt.Sprintf("%d lines dropped since the reader could not keep up", r.skipped[component])
+			r.queue = append(r.queue, OutputRecord{
+				ComponentID: component,
+				Time:        now,
+				Stream:      OutputStreamStdout,
+				Level:       LogLevelInfo,
+				Text:        text,
+				Spans:       []OutputSpan{{Start: 0, End: len(text), Color: "yellow"}},
+				Attributes:  map[string]any{droppedAttribute: r.skipped[component]},
+			})
+			delete(r.skipped, component)
+		}
+	}
+
+	r.queue = append(r.queue, entry.record)
+	r.cond.Signal()
+	return true
+}
+
+// deliver sends queued records to the reader channel until the reader is closed or disconnected.
+func (r *Reader) deliver() {
+	defer close(r.records)
+	for {
+		r.lock.Lock()
+		for len(r.queue) == 0 && !r.closed {
+			r.cond.Wait()
+		}
+		if len(r.queue) == 0 {
+			r.lock.Unlock()
+			return
+		}
+		record := r.queue[0]
+		r.queue[0] = OutputRecord{}
+		r.queue = r.queue[1:]
+		if r.replaying > 0 {
+			r.replaying--
+		}
+		r.lock.Unlock()
+
+		select {
+		case r.records <- record:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// shutdown stops delivering messages and closes the reader channel.
+func (r *Reader) shutdown() {
+	r.closeOnce.Do(func() {
+		r.lock.Lock()
+		r.closed = true
+		r.queue = nil
+		r.cond.Signal()
+		r.lock.Unlock()
+		close(r.done)
+	})
+}
+
+// finish stops the reader from receiving new messages, and closes the reader channel once all messages that were
+// already queued are delivered. The reader should still be closed once the channel is drained.
+func (r *Reader) finish() {
+	r.lock.Lock()
+	r.closed = true
+	r.cond.Signal()
+	r.lock.Unlock()
 }
 
-// Chan returns the channel for receiving log messages.
-func (o *Reader) Chan() chan []byte {
-	return o.ch
+// Records returns the channel for receiving output records. The channel is closed when the reader is closed,
+// or disconnected for being too slow, see DisconnectIfSlow.
+// Records and Chan deliver the same messages, so a Reader should be consumed using only one of them.
+func (r *Reader) Records() <-chan OutputRecord {
+	return r.records
+}
+
+// Chan returns the channel for receiving log messages in the legacy tagged text format of the /output API,
+// i.e. <component>ID<time>TIMESTAMP<msg>TEXT followed by a newline, where TEXT is rendered with ANSI escape codes.
+// The channel is closed when the reader is closed, or disconnected for being too slow, see DisconnectIfSlow.
+// Records and Chan deliver the same messages, so a Reader should be consumed using only one of them.
+func (r *Reader) Chan() chan []byte {
+	r.legacyOnce.Do(func() {
+		r.legacy = make(chan []byte)
+		go func() {
+			defer close(r.legacy)
+			for record := range r.records {
+				select {
+				case r.legacy <- record.legacy(OutputRenderingANSI):
+				case <-r.done:
+					return
+				}
+			}
+		}()
+	})
+	return r.legacy
+}
+
+// Dropped returns the number of messages the reader missed since it did not keep up with the output.
+func (r *Reader) Dropped() uint64 {
+	return r.dropped.Load()
 }
 
 // Close closes the log message reader.
-func (o *Reader) Close() error {
-	o.close()
+func (r *Reader) Close() error {
+	r.close()
 	return nil
 }
 
@@ -124,13 +616,70 @@ func (w *Writer) WriteString(message string) {
 
 // WriteStringWithTime writes a log message with a specified timestamp.
 func (w *Writer) WriteStringWithTime(t time.Time, message string) {
-	if strings.HasSuffix(message, "\r\n") {
-		message = message[:len(message)-2]
+	w.WriteRecord(OutputRecord{Time: t, Stream: OutputStreamStdout, Level: LogLevelInfo, Text: message})
+}
+
+// Log writes a log message with the current timestamp at the given level. args are key/value pairs or slog.Attr
+// values, handled the same way as by slog.Logger.Log, and are written as the attributes of the record.
+func (w *Writer) Log(level LogLevel, message string, args ...any) {
+	record := slog.NewRecord(time.Now(), slogLevel(level), message, 0)
+	record.Add(args...)
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	w.WriteRecord(OutputRecord{
+		Time:       record.Time,
+		Stream:     OutputStreamStdout,
+		Level:      level,
+		Text:       message,
+		Attributes: attributes(attrs),
+	})
+}
+
+// Debug writes a log message at LogLevelDebug, see Log.
+func (w *Writer) Debug(message string, args ...any) {
+	w.Log(LogLevelDebug, message, args...)
+}
+
+// Info writes a log message at LogLevelInfo, see Log.
+func (w *Writer) Info(message string, args ...any) {
+	w.Log(LogLevelInfo, message, args...)
+}
+
+// Warn writes a log message at LogLevelWarn, see Log.
+func (w *Writer) Warn(message string, args ...any) {
+	w.Log(LogLevelWarn, message, args...)
+}
+
+// Error writes a log message at LogLevelError, see Log.
+func (w *Writer) Error(message string, args ...any) {
+	w.Log(LogLevelError, message, args...)
+}
+
+// Logger returns a *slog.Logger whose records are written by the writer, with their levels, attributes and groups.
+// Records below minLevel are discarded, and a nil minLevel keeps all records.
+func (w *Writer) Logger(minLevel slog.Leveler) *slog.Logger {
+	return slog.New(&writerHandler{writer: w, level: minLevel})
+}
+
+// WriteRecord writes a structured log record. The component ID of the record is set to the component of the writer,
+// and a zero Time is set to the current time. ANSI escape codes are removed from the text, and the colors they set
+// become the spans of the record, unless the record already has spans. Any other field is kept as is.
+func (w *Writer) WriteRecord(record OutputRecord) {
+	record.ComponentID = w.component
+	if record.Time.IsZero() {
+		record.Time = time.Now()
 	}
-	if strings.HasSuffix(message, "\n") {
-		message = message[:len(message)-1]
+	record.Text = strings.TrimSuffix(record.Text, "\r\n")
+	record.Text = strings.TrimSuffix(record.Text, "\n")
+	text, spans := parseANSI(record.Text)
+	record.Text = text
+	if len(record.Spans) == 0 {
+		record.Spans = spans
 	}
-	w.outputManager.write(t, w.component, message)
+	w.outputManager.write(record)
 }
 
 // AnsiColor provides ANSI color codes for console output.
@@ -0,0 +1,239 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxFinishedJobs is the number of finished jobs kept by a jobManager before the oldest ones are discarded.
+const maxFinishedJobs = 100
+
+// JobStatus represents the status of an asynchronous operation requested via the API.
+type JobStatus string
+
+const (
+	// JobStatusQueued indicates the job waits for another operation on the environment to finish.
+	JobStatusQueued JobStatus = "queued"
+
+	// JobStatusRunning indicates the operation of the job is running.
+	JobStatusRunning JobStatus = "running"
+
+	// JobStatusSucceeded indicates the operation of the job completed successfully.
+	JobStatusSucceeded JobStatus = "succeeded"
+
+	// JobStatusFailed indicates the operation of the job returned an error.
+	JobStatusFailed JobStatus = "failed"
+
+	// JobStatusCanceled indicates the job was canceled before its operation completed.
+	JobStatusCanceled JobStatus = "canceled"
+)
+
+// Job describes an asynchronous operation requested via the API and its progress.
+//
+// Fields:
+// - ID: A unique identifier for the job.
+// - Operation: The operation the job performs.
+// - ComponentID: The component the operation was requested for, if any.
+// - Status: The current status of the job.
+// - CreatedAt: The time the job was requested.
+// - FinishedAt: The time the job finished, if it did.
+// - Components: The latest event of each component the operation acted on so far, in the order first acted on.
+// - Cascade: The components planned to be restarted after the component of the operation changed, see WithCascade.
+// - Result: The response body the equivalent synchronous request would return, once the job succeeded.
+// - Error: The error the operation failed with, if any.
+// - Details: Structured information about the error, such as an *ApplyError.
+type Job struct {
+	ID          string     `json:"id"`
+	Operation   Operation  `json:"operation"`
+	ComponentID string     `json:"component_id,omitempty"`
+	Status      JobStatus  `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Components  []Event    `json:"components"`
+	Cascade     []string   `json:"cascade,omitempty"`
+	Result      any        `json:"result,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Details     any        `json:"details,omitempty"`
+}
+
+// jobFunc runs the operation of a job. It must pass options to every operation it runs on the environment.
+type jobFunc func(ctx context.Context, options []OperationOption) (any, error)
+
+// jobManager runs asynchronous operations on an Environment and keeps track of their progress.
+type jobManager struct {
+	ctx      context.Context
+	lock     sync.Mutex
+	jobs     map[string]*job
+	finished []string
+}
+
+// job is a single job tracked by a jobManager.
+type job struct {
+	info       Job
+	components map[string]int
+	cancel     context.CancelFunc
+}
+
+// newJobManager creates a new instance of jobManager with no jobs.
+// The contexts of all jobs are derived from ctx, so canceling ctx cancels all jobs.
+func newJobManager(ctx context.Context) *jobManager {
+	return &jobManager{ctx: ctx, jobs: make(map[string]*job)}
+}
+
+// start creates a job and runs fn in the background with the given options, returning the job as it was created.
+func (m *jobManager) start(
+	operation Operation,
+	componentID string,
+	options []OperationOption,
+	fn jobFunc,
+) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	j := &job{
+		info: Job{
+			ID:          id,
+			Operation:   operation,
+			ComponentID: componentID,
+			Status:      JobStatusQueued,
+			CreatedAt:   time.Now(),
+			Components:  []Event{},
+		},
+		components: make(map[string]int),
+		cancel:     cancel,
+	}
+
+	m.lock.Lock()
+	m.jobs[id] = j
+	info := j.info
+	m.lock.Unlock()
+
+	go func() {
+		defer cancel()
+		observer := withObserver(func(event Event) {
+			m.observe(j, event)
+		})
+		result, err := fn(ctx, append(append([]OperationOption{}, options...), observer))
+		m.finish(ctx, j, result, err)
+	}()
+
+	return info, nil
+}
+
+// get returns the job identified by id.
+func (m *jobManager) get(id string) (Job, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound{id: id}
+	}
+	return j.snapshot(), nil
+}
+
+// cancel cancels the context of the job identified by id and returns the job.
+// Canceling a job that already finished has no effect.
+func (m *jobManager) cancel(id string) (Job, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound{id: id}
+	}
+	j.cancel()
+	return j.snapshot(), nil
+}
+
+// observe updates the progress of a job with an event published during its operation.
+func (m *jobManager) observe(j *job, event Event) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if event.Type == EventOperationStarted {
+		j.info.Status = JobStatusRunning
+		return
+	}
+	if event.Type == EventCascadePlanned {
+		j.info.Cascade = append(j.info.Cascade, event.Components...)
+		return
+	}
+	if event.Type == EventOperationFinished {
+		return
+	}
+
+	if i, ok := j.components[event.ComponentID]; ok {
+		j.info.Components[i] = event
+		return
+	}
+	j.components[event.ComponentID] = len(j.info.Components)
+	j.info.Components = append(j.info.Components, event)
+}
+
+// finish records the result of a job, discarding the oldest finished jobs if there are too many.
+func (m *jobManager) finish(ctx context.Context, j *job, result any, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := time.Now()
+	j.info.FinishedAt = &now
+	switch {
+	case err == nil:
+		j.info.Status = JobStatusSucceeded
+		j.info.Result = result
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		j.info.Status = JobStatusCanceled
+		j.info.Error = err.Error()
+	default:
+		j.info.Status = JobStatusFailed
+		j.info.Error = err.Error()
+	}
+
+	var applyErr *ApplyError
+	if errors.As(err, &applyErr) {
+		j.info.Details = applyErr
+	}
+
+	m.finished = append(m.finished, j.info.ID)
+	for len(m.finished) > maxFinishedJobs {
+		delete(m.jobs, m.finished[0])
+		m.finished = m.finished[1:]
+	}
+}
+
+// snapshot returns a copy of the job info that is safe to use after the jobManager lock is released.
+func (j *job) snapshot() Job {
+	result := j.info
+	result.Components = append([]Event{}, j.info.Components...)
+	result.Cascade = append([]string(nil), j.info.Cascade...)
+	return result
+}
+
+// newJobID generates a new random job ID.
+func newJobID() (string, error) {
+	data := make([]byte, 8)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", fmt.Errorf("could not generate job id: %w", err)
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// ErrJobNotFound is an error type representing a job ID that does not exist.
+type ErrJobNotFound struct {
+	id string
+}
+
+func (e ErrJobNotFound) Error() string {
+	return fmt.Sprintf("job %s not found", e.id)
+}
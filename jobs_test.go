@@ -0,0 +1,101 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIJobs(t *testing.T) {
+	db := &mockComponent{}
+	service := &flakyComponent{mockComponent: &mockComponent{}}
+	service.hang.Store(true)
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().
+			AddComponent("db", db).
+			AddComponent("service", service, "db"),
+	)
+	assert.NoError(t, err)
+
+	router := mux.NewRouter()
+	registerRoutes(context.Background(), router, env)
+	call := func(method, path, body string) (int, Job) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(contentType, applicationJSON)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		var job Job
+		if res.Code < http.StatusBadRequest {
+			err := json.Unmarshal(res.Body.Bytes(), &job)
+			assert.NoError(t, err)
+		}
+		return res.Code, job
+	}
+	componentEvent := func(job Job, id string) EventType {
+		for _, event := range job.Components {
+			if event.ComponentID == id {
+				return event.Type
+			}
+		}
+		return ""
+	}
+	waitForJob := func(id string, condition func(job Job) bool) Job {
+		var job Job
+		assert.Eventually(t, func() bool {
+			var status int
+			status, job = call(http.MethodGet, "/jobs/"+id, "")
+			assert.Equal(t, http.StatusOK, status)
+			return condition(job)
+		}, 5*time.Second, 10*time.Millisecond)
+		return job
+	}
+
+	status, job := call(http.MethodPost, "/apply?async=true", `{"enabled_component_ids": ["db", "service"]}`)
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.NotEmpty(t, job.ID)
+	assert.Equal(t, OperationApply, job.Operation)
+	assert.Contains(t, []JobStatus{JobStatusQueued, JobStatusRunning}, job.Status)
+
+	job = waitForJob(job.ID, func(job Job) bool {
+		return componentEvent(job, "service") == EventComponentStarting
+	})
+	assert.Equal(t, JobStatusRunning, job.Status)
+	assert.Equal(t, EventComponentRunning, componentEvent(job, "db"))
+
+	status, _ = call(http.MethodDelete, "/jobs/"+job.ID, "")
+	assert.Equal(t, http.StatusOK, status)
+	job = waitForJob(job.ID, func(job Job) bool {
+		return job.FinishedAt != nil
+	})
+	assert.Equal(t, JobStatusCanceled, job.Status)
+	assert.Contains(t, job.Error, "context canceled")
+	assert.NotNil(t, job.Details)
+	assert.Equal(t, EventComponentFailed, componentEvent(job, "service"))
+
+	service.hang.Store(false)
+	status, job = call(http.MethodPost, "/start_component?async=true", `{"component_id": "service"}`)
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Equal(t, OperationStartComponent, job.Operation)
+	assert.Equal(t, "service", job.ComponentID)
+	job = waitForJob(job.ID, func(job Job) bool {
+		return job.FinishedAt != nil
+	})
+	assert.Equal(t, JobStatusSucceeded, job.Status)
+	assert.Equal(t, map[string]any{"started": []any{"service"}}, job.Result)
+
+	status, _ = call(http.MethodGet, "/jobs/missing", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = call(http.MethodDelete, "/jobs/missing", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// componentFailure describes the latest failure of a component, reported by Environment.Status
+// until the component is successfully operated on again.
+type componentFailure struct {
+	reason ComponentFailureReason
+	err    string
+}
+
+// componentFailures tracks the latest failure of each component. It is safe for concurrent use.
+type componentFailures struct {
+	lock     sync.Mutex
+	failures map[string]componentFailure
+}
+
+// newComponentFailures creates a new empty componentFailures.
+func newComponentFailures() *componentFailures {
+	return &componentFailures{failures: make(map[string]componentFailure)}
+}
+
+// set records the failure of a component, determining its reason from the error returned by the failed action.
+func (f *componentFailures) set(id string, action ComponentAction, err error) {
+	reason := ComponentFailureReasonError
+	var timeoutErr ErrTimeout
+	if errors.As(err, &timeoutErr) {
+		switch action {
+		case ComponentActionStart, ComponentActionRestart:
+			reason = ComponentFailureReasonStartTimeout
+		case ComponentActionStop:
+			reason = ComponentFailureReasonStopTimeout
+		}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.failures[id] = componentFailure{reason: reason, err: err.Error()}
+}
+
+// clear removes the recorded failure of a component.
+func (f *componentFailures) clear(id string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.failures, id)
+}
+
+// get returns the recorded failure of a component, if any.
+func (f *componentFailures) get(id string) (componentFailure, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	failure, ok := f.failures[id]
+	return failure, ok
+}
+
+// withRetries runs fn, retrying it according to the LifecyclePolicy of the component if it fails.
+// The delay between attempts starts at the configured backoff and doubles after each attempt.
+// An attempt that timed out and is still running is not retried, so that attempts never run concurrently.
+func (b *Environment) withRetries(ctx context.Context, id string, action ComponentAction, fn func() error) error {
+	policy := b.policies[id]
+	backoff := policy.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.Retries || ctx.Err() != nil {
+			return err
+		}
+		var timeoutErr ErrTimeout
+		if errors.As(err, &timeoutErr) && timeoutErr.running {
+			return err
+		}
+
+		b.Logger(LogLevelInfo, fmt.Sprintf("could not %s %s, retrying in %s: %v", action, id, backoff, err))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// withTimeout runs fn with a context that expires after timeout, returning ErrTimeout if it does not complete in time.
+// Since components might not respect context cancellation, withTimeout waits for fn to return after its context is
+// canceled for at most the same timeout again. If fn is still running by then, withTimeout returns without waiting
+// any longer, and the returned ErrTimeout reports it. A timeout of zero or less runs fn with no timeout.
+func withTimeout(
+	ctx context.Context,
+	id string,
+	action ComponentAction,
+	timeout time.Duration,
+	fn func(ctx context.Context) error,
+) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- fn(timeoutCtx)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil && ctx.Err() == nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			return ErrTimeout{id: id, action: action, timeout: timeout}
+		}
+		return err
+	case <-timeoutCtx.Done():
+	}
+
+	grace := time.NewTimer(timeout)
+	defer grace.Stop()
+	running := false
+	select {
+	case <-result:
+	case <-grace.C:
+		running = true
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return ErrTimeout{id: id, action: action, timeout: timeout, running: running}
+}
+
+// ErrTimeout represents an error when a component action does not complete within the timeout
+// configured by its LifecyclePolicy.
+type ErrTimeout struct {
+	id      string
+	action  ComponentAction
+	timeout time.Duration
+	running bool
+}
+
+func (e ErrTimeout) Error() string {
+	if e.running {
+		return fmt.Sprintf("%s of %s timed out after %s and is still running", e.action, e.id, e.timeout)
+	}
+	return fmt.Sprintf("%s of %s timed out after %s", e.action, e.id, e.timeout)
+}
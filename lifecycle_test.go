@@ -0,0 +1,181 @@
+package envite
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type flakyComponent struct {
+	*mockComponent
+	failures     int32
+	attempts     atomic.Int32
+	hang         atomic.Bool
+	prepareFails int
+	prepares     int
+}
+
+func (f *flakyComponent) Prepare(ctx context.Context) error {
+	f.prepares++
+	if f.prepares <= f.prepareFails {
+		return errors.New("prepare error")
+	}
+	return f.mockComponent.Prepare(ctx)
+}
+
+func (f *flakyComponent) Start(ctx context.Context) error {
+	attempts := f.attempts.Add(1)
+	if f.hang.Load() {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if attempts <= f.failures {
+		return errors.New("flaky start error")
+	}
+	return f.mockComponent.Start(ctx)
+}
+
+func (f *flakyComponent) Stop(ctx context.Context) error {
+	if f.hang.Load() {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return f.mockComponent.Stop(ctx)
+}
+
+func TestLifecyclePolicyRetries(t *testing.T) {
+	component := &flakyComponent{mockComponent: &mockComponent{}, failures: 2, prepareFails: 1}
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("flaky", component),
+		WithLifecyclePolicy("flaky", LifecyclePolicy{Retries: 2, RetryBackoff: time.Millisecond}),
+	)
+	assert.NoError(t, err)
+
+	err = env.StartAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, component.prepares)
+	assert.Equal(t, int32(3), component.attempts.Load())
+	assert.Equal(t, ComponentStatusRunning, component.status)
+
+	// Exhaust retries
+	component.status = ComponentStatusStopped
+	component.attempts.Store(0)
+	component.failures = 5
+	_, err = env.StartComponent(context.Background(), "flaky")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "flaky start error")
+	assert.Equal(t, int32(3), component.attempts.Load())
+
+	status, err := env.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ComponentStatusFailed, status.Components[0][0].Status)
+	assert.Equal(t, ComponentFailureReasonError, status.Components[0][0].FailureReason)
+	assert.Contains(t, status.Components[0][0].Error, "flaky start error")
+}
+
+func TestLifecyclePolicyTimeouts(t *testing.T) {
+	component := &flakyComponent{mockComponent: &mockComponent{}}
+	component.hang.Store(true)
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("hanging", component),
+		WithLifecyclePolicy("hanging", LifecyclePolicy{
+			StartTimeout: 10 * time.Millisecond,
+			StopTimeout:  10 * time.Millisecond,
+		}),
+	)
+	assert.NoError(t, err)
+
+	_, err = env.StartComponent(context.Background(), "hanging")
+	assert.Error(t, err)
+	assert.ErrorAs(t, err, &ErrTimeout{})
+	assert.Contains(t, err.Error(), "start of hanging timed out after 10ms")
+
+	status, err := env.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ComponentStatusFailed, status.Components[0][0].Status)
+	assert.Equal(t, ComponentFailureReasonStartTimeout, status.Components[0][0].FailureReason)
+
+	_, err = env.StopComponent(context.Background(), "hanging")
+	assert.Error(t, err)
+	status, err = env.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ComponentFailureReasonStopTimeout, status.Components[0][0].FailureReason)
+
+	// A successful action clears the failure
+	component.hang.Store(false)
+	_, err = env.StartComponent(context.Background(), "hanging")
+	assert.NoError(t, err)
+	status, err = env.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ComponentStatusRunning, status.Components[0][0].Status)
+	assert.Empty(t, status.Components[0][0].FailureReason)
+}
+
+// stubbornComponent is a component whose Start ignores its context, blocking for a fixed delay.
+type stubbornComponent struct {
+	*mockComponent
+	delay       time.Duration
+	attempts    atomic.Int32
+	running     atomic.Int32
+	concurrency atomic.Int32
+}
+
+func (s *stubbornComponent) Start(context.Context) error {
+	s.attempts.Add(1)
+	if running := s.running.Add(1); running > s.concurrency.Load() {
+		s.concurrency.Store(running)
+	}
+	defer s.running.Add(-1)
+	time.Sleep(s.delay)
+	return errors.New("stubborn start error")
+}
+
+func TestLifecyclePolicyTimeoutIgnoringContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		attempts int32
+		running  bool
+	}{
+		{name: "returns within grace period", delay: 30 * time.Millisecond, attempts: 3},
+		{name: "still running after grace period", delay: 200 * time.Millisecond, attempts: 1, running: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			component := &stubbornComponent{mockComponent: &mockComponent{}, delay: tt.delay}
+			env, err := NewEnvironment(
+				"test-env",
+				NewComponentGraph().AddComponent("stubborn", component),
+				WithLifecyclePolicy("stubborn", LifecyclePolicy{
+					StartTimeout: 20 * time.Millisecond,
+					Retries:      2,
+					RetryBackoff: time.Millisecond,
+				}),
+			)
+			assert.NoError(t, err)
+
+			_, err = env.StartComponent(context.Background(), "stubborn")
+			var timeoutErr ErrTimeout
+			assert.ErrorAs(t, err, &timeoutErr)
+			assert.Equal(t, tt.running, timeoutErr.running)
+			assert.Equal(t, tt.attempts, component.attempts.Load())
+			assert.Equal(t, int32(1), component.concurrency.Load())
+		})
+	}
+}
+
+func TestLifecyclePolicyInvalidComponent(t *testing.T) {
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("component", &mockComponent{}),
+		WithLifecyclePolicy("missing", LifecyclePolicy{Retries: 1}),
+	)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "missing")
+	assert.Nil(t, env)
+}
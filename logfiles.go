@@ -0,0 +1,330 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// combinedLogFile is the name of the log file holding the output of all components, without an extension.
+const combinedLogFile = "combined"
+
+// LogFileFormat represents the format of the log files written by the Environment, see WithLogFiles.
+type LogFileFormat string
+
+const (
+	// LogFileFormatPlain writes each record as a line of text holding its time, component, stream and text.
+	// This is the default format.
+	LogFileFormatPlain LogFileFormat = "plain"
+
+	// LogFileFormatNDJSON writes each record as a JSON encoded OutputRecord followed by a newline.
+	LogFileFormatNDJSON LogFileFormat = "ndjson"
+)
+
+// ParseLogFileFormat parses the provided string value into a LogFileFormat.
+// It returns the parsed LogFileFormat or an error if the value is not a valid log file format.
+func ParseLogFileFormat(value string) (LogFileFormat, error) {
+	switch LogFileFormat(value) {
+	case LogFileFormatPlain, "":
+		return LogFileFormatPlain, nil
+	case LogFileFormatNDJSON:
+		return LogFileFormatNDJSON, nil
+	}
+	return "", ErrInvalidLogFileFormat{v: value}
+}
+
+// LogFiles configures the log files the Environment mirrors its output to, see WithLogFiles.
+//
+// Fields:
+// - Dir: The directory to write log files to. It is created if it does not exist.
+// - Format: The format of the log files. Defaults to LogFileFormatPlain.
+// - MaxSize: The size in bytes a log file may reach before it is rotated. Zero means log files are never rotated.
+// - MaxBackups: The number of rotated files kept for each log file. Zero means all rotated files are kept.
+// - Rendering: How colored output is rendered in the log files. Defaults to OutputRenderingPlain.
+type LogFiles struct {
+	Dir        string
+	Format     LogFileFormat
+	MaxSize    int64
+	MaxBackups int
+	Rendering  OutputRendering
+}
+
+// logFiles writes output records to a combined log file and to a log file per component, rotating them by size.
+// Rotated files are named after the log file with a numeric suffix, where .1 is the most recently rotated.
+type logFiles struct {
+	lock   sync.Mutex
+	config LogFiles
+	files  map[string]*logFile
+}
+
+// logFile is a single log file written by logFiles.
+type logFile struct {
+	path string
+	file *os.File
+	size int64
+}
+
+// newLogFiles creates a new instance of logFiles with the given configuration.
+func newLogFiles(config LogFiles) *logFiles {
+	if config.Format == "" {
+		config.Format = LogFileFormatPlain
+	}
+	if config.Rendering == "" {
+		config.Rendering = OutputRenderingPlain
+	}
+	return &logFiles{config: config, files: make(map[string]*logFile)}
+}
+
+// init validates the configuration and creates the log files directory.
+func (l *logFiles) init() error {
+	_, err := ParseLogFileFormat(string(l.config.Format))
+	if err != nil {
+		return err
+	}
+	_, err = ParseOutputRendering(string(l.config.Rendering))
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(l.config.Dir, 0o755)
+	if err != nil {
+		return fmt.Errorf("could not create log files directory %s: %w", l.config.Dir, err)
+	}
+	return nil
+}
+
+// write appends record to the combined log file and to the log file of its component.
+func (l *logFiles) write(record OutputRecord) error {
+	data, err := l.format(record)
+	if err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	err = l.writeFile(combinedLogFile, data)
+	if err != nil {
+		return err
+	}
+	return l.writeFile(logFileName(record.ComponentID), data)
+}
+
+// close closes all open log files. They are opened again if written to afterward.
+func (l *logFiles) close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var errs []error
+	for _, f := range l.files {
+		if f.file == nil {
+			continue
+		}
+		err := f.file.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not close log file %s: %w", f.path, err))
+		}
+		f.file = nil
+	}
+	return errors.Join(errs...)
+}
+
+// format encodes record in the configured log file format.
+func (l *logFiles) format(record OutputRecord) ([]byte, error) {
+	record = record.rendered(l.config.Rendering)
+	if l.config.Format == LogFileFormatNDJSON {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal output record: %w", err)
+		}
+		return append(data, '\n'), nil
+	}
+	return []byte(fmt.Sprintf(
+		"%s %s %s %s\n",
+		record.Time.Local().Format(timeFormat),
+		record.ComponentID,
+		record.Stream,
+		record.Text,
+	)), nil
+}
+
+// writeFile appends data to the log file with the given name, rotating it first if data would exceed its max size.
+// It must be called while holding the lock.
+func (l *logFiles) writeFile(name string, data []byte) error {
+	f, ok := l.files[name]
+	if !ok {
+		f = &logFile{path: filepath.Join(l.config.Dir, name+l.extension())}
+		l.files[name] = f
+	}
+
+	err := f.open()
+	if err != nil {
+		return err
+	}
+	if l.config.MaxSize > 0 && f.size > 0 && f.size+int64(len(data)) > l.config.MaxSize {
+		err = l.rotate(f)
+		if err != nil {
+			return err
+		}
+		err = f.open()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("could not write log file %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// open opens the log file for appending, unless it is already open.
+func (f *logFile) open() error {
+	if f.file != nil {
+		return nil
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open log file %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("could not open log file %s: %w", f.path, err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate closes a log file and shifts it and its rotated files by one, discarding the oldest beyond max backups.
+// It must be called while holding the lock.
+func (l *logFiles) rotate(f *logFile) error {
+	err := f.file.Close()
+	f.file = nil
+	f.size = 0
+	if err != nil {
+		return fmt.Errorf("could not close log file %s: %w", f.path, err)
+	}
+
+	last := l.config.MaxBackups
+	if last == 0 {
+		last = 1
+		for fileExists(rotatedPath(f.path, last)) {
+			last++
+		}
+	}
+	err = os.Remove(rotatedPath(f.path, last))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not remove rotated log file: %w", err)
+	}
+	for i := last - 1; i >= 0; i-- {
+		err = os.Rename(rotatedPath(f.path, i), rotatedPath(f.path, i+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not rotate log file %s: %w", f.path, err)
+		}
+	}
+	return nil
+}
+
+// paths returns the paths of all log files written so far, including rotated ones.
+func (l *logFiles) paths() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var result []string
+	for _, f := range l.files {
+		result = append(result, f.path)
+		for i := 1; l.config.MaxBackups == 0 || i <= l.config.MaxBackups; i++ {
+			path := rotatedPath(f.path, i)
+			if !fileExists(path) {
+				break
+			}
+			result = append(result, path)
+		}
+	}
+	return result
+}
+
+// zip writes a zip archive of all log files to w. Files rotated away while zipping are skipped.
+func (l *logFiles) zip(w io.Writer) error {
+	paths := l.paths()
+	sort.Strings(paths)
+
+	archive := zip.NewWriter(w)
+	for _, path := range paths {
+		err := zipFile(archive, path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// zipFile adds the file at path to archive under its base name.
+func zipFile(archive *zip.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	entry, err := archive.Create(filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("could not add %s to archive: %w", path, err)
+	}
+	_, err = io.Copy(entry, file)
+	if err != nil {
+		return fmt.Errorf("could not add %s to archive: %w", path, err)
+	}
+	return nil
+}
+
+// extension returns the file extension of log files in the configured format.
+func (l *logFiles) extension() string {
+	if l.config.Format == LogFileFormatNDJSON {
+		return ".ndjson"
+	}
+	return ".log"
+}
+
+// logFileName returns the name of the log file of a component, replacing characters that are not allowed in file names.
+func logFileName(componentID string) string {
+	return "component-" + strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(componentID)
+}
+
+// rotatedPath returns the path of the i-th rotated file of the log file at path. The 0-th is the log file itself.
+func rotatedPath(path string, i int) string {
+	if i == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// fileExists reports whether a file exists at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// ErrInvalidLogFileFormat is an error type representing an invalid log file format.
+type ErrInvalidLogFileFormat struct {
+	v string
+}
+
+func (e ErrInvalidLogFileFormat) Error() string {
+	return fmt.Sprintf("invalid log file format %s", e.v)
+}
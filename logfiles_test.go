@@ -0,0 +1,173 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFiles(t *testing.T) {
+	api := &mockComponent{}
+	db := &mockComponent{}
+	dir := filepath.Join(t.TempDir(), "logs")
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("api", api).AddComponent("db", db),
+		WithLogFiles(LogFiles{Dir: dir}),
+	)
+	assert.NoError(t, err)
+
+	api.w.WriteString("api " + api.w.Color.Green("message"))
+	db.w.WriteRecord(OutputRecord{Stream: OutputStreamStderr, Text: "db message"})
+
+	// colors are stripped by default
+	combined := readLines(t, filepath.Join(dir, "combined.log"))
+	assert.Len(t, combined, 2)
+	assert.True(t, strings.HasSuffix(combined[0], " api stdout api message"))
+	assert.True(t, strings.HasSuffix(combined[1], " db stderr db message"))
+	assert.Equal(t, combined[:1], readLines(t, filepath.Join(dir, "component-api.log")))
+	assert.Equal(t, combined[1:], readLines(t, filepath.Join(dir, "component-db.log")))
+	assert.NotNil(t, env)
+}
+
+func TestLogFilesOnAttach(t *testing.T) {
+	component := &eagerComponent{mockComponent: &mockComponent{}, lines: []string{"eager message"}}
+	dir := filepath.Join(t.TempDir(), "logs")
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("eager", component),
+		WithLogFiles(LogFiles{Dir: dir}),
+	)
+	assert.NoError(t, err)
+
+	// output written while attaching is mirrored, and log files remain enabled
+	component.w.WriteString("later message")
+	combined := readLines(t, filepath.Join(dir, "combined.log"))
+	assert.Len(t, combined, 2)
+	assert.True(t, strings.HasSuffix(combined[0], " eager stdout eager message"))
+	assert.True(t, strings.HasSuffix(combined[1], " eager stdout later message"))
+	assert.NotNil(t, env)
+}
+
+func TestLogFilesNDJSON(t *testing.T) {
+	component := &mockComponent{}
+	dir := t.TempDir()
+	_, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("component", component),
+		WithLogFiles(LogFiles{Dir: dir, Format: LogFileFormatNDJSON}),
+	)
+	assert.NoError(t, err)
+
+	someTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	component.w.WriteStringWithTime(someTime, "message")
+
+	lines := readLines(t, filepath.Join(dir, "component-component.ndjson"))
+	assert.Len(t, lines, 1)
+	var record OutputRecord
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, OutputRecord{
+		ComponentID: "component",
+		Time:        someTime,
+		Stream:      OutputStreamStdout,
+		Level:       LogLevelInfo,
+		Text:        "message",
+	}, record)
+	assert.Equal(t, lines, readLines(t, filepath.Join(dir, "combined.ndjson")))
+
+	ansiDir := t.TempDir()
+	_, err = NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("component", component),
+		WithLogFiles(LogFiles{Dir: ansiDir, Rendering: OutputRenderingANSI}),
+	)
+	assert.NoError(t, err)
+	component.w.WriteString(component.w.Color.Red("colored"))
+	assert.Contains(t, readLines(t, filepath.Join(ansiDir, "combined.log"))[0], "\u001B[31mcolored\u001B[39m")
+
+	_, err = NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("component", &mockComponent{}),
+		WithLogFiles(LogFiles{Dir: dir, Format: "xml"}),
+	)
+	assert.ErrorIs(t, err, ErrInvalidLogFileFormat{v: "xml"})
+}
+
+func TestLogFilesRotation(t *testing.T) {
+	component := &mockComponent{}
+	dir := t.TempDir()
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("component", component),
+		WithLogFiles(LogFiles{Dir: dir, Format: LogFileFormatNDJSON, MaxSize: 150, MaxBackups: 2}),
+	)
+	assert.NoError(t, err)
+
+	// each record is a bit over 100 bytes, so every record rotates the files
+	for _, text := range []string{"first", "second", "third", "fourth"} {
+		component.w.WriteString(text)
+	}
+
+	path := filepath.Join(dir, "combined.ndjson")
+	assert.Contains(t, readLines(t, path)[0], "fourth")
+	assert.Contains(t, readLines(t, path+".1")[0], "third")
+	assert.Contains(t, readLines(t, path+".2")[0], "second")
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	req := httptest.NewRequest(http.MethodGet, "/output/download", nil)
+	res := httptest.NewRecorder()
+	getOutputDownloadHandler{env: env}.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/zip", res.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="test-env-logs.zip"`, res.Header().Get("Content-Disposition"))
+
+	archive, err := zip.NewReader(bytes.NewReader(res.Body.Bytes()), int64(res.Body.Len()))
+	assert.NoError(t, err)
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+		if file.Name == "combined.ndjson.2" {
+			reader, err := file.Open()
+			assert.NoError(t, err)
+			data, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Contains(t, string(data), "second")
+		}
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{
+		"combined.ndjson",
+		"combined.ndjson.1",
+		"combined.ndjson.2",
+		"component-component.ndjson",
+		"component-component.ndjson.1",
+		"component-component.ndjson.2",
+	}, names)
+
+	env, err = NewEnvironment("test-env", NewComponentGraph().AddComponent("component", &mockComponent{}))
+	assert.NoError(t, err)
+	res = httptest.NewRecorder()
+	getOutputDownloadHandler{env: env}.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func readLines(t *testing.T, path string) []string {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LineMatcher matches lines of output, such as lines containing a string or lines matching a regular expression.
+// It is used to wait for output, see ComponentOutput.WaitFor.
+type LineMatcher interface {
+	// Match reports whether the text of a line of output matches.
+	Match(text string) bool
+
+	// String describes the lines that match, for use in messages.
+	String() string
+}
+
+// LineContaining returns a LineMatcher matching lines that contain substring.
+func LineContaining(substring string) LineMatcher {
+	return stringMatcher{substring: substring}
+}
+
+// LineMatching returns a LineMatcher matching lines that match pattern.
+func LineMatching(pattern *regexp.Regexp) LineMatcher {
+	return regexMatcher{pattern: pattern}
+}
+
+// stringMatcher is a LineMatcher matching lines that contain a substring.
+type stringMatcher struct {
+	substring string
+}
+
+func (m stringMatcher) Match(text string) bool {
+	return strings.Contains(text, m.substring)
+}
+
+func (m stringMatcher) String() string {
+	return fmt.Sprintf("'%s'", m.substring)
+}
+
+// regexMatcher is a LineMatcher matching lines that match a regular expression.
+type regexMatcher struct {
+	pattern *regexp.Regexp
+}
+
+func (m regexMatcher) Match(text string) bool {
+	return m.pattern.MatchString(text)
+}
+
+func (m regexMatcher) String() string {
+	return fmt.Sprintf("regex '%s'", m.pattern)
+}
@@ -0,0 +1,286 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"encoding"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// openAPIPath is the path the OpenAPI document of the API is served at.
+	openAPIPath = "/openapi.json"
+
+	openAPIVersion     = "3.0.3"
+	openAPITitle       = "ENVITE API"
+	openAPIInfoVersion = "1.0.0"
+)
+
+// openAPIDocument is an OpenAPI document describing the API.
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+	Security   []map[string][]string                  `json:"security"`
+}
+
+// openAPIInfo holds the metadata of an openAPIDocument.
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// openAPIOperation describes a single endpoint of the API.
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+// openAPIParameter describes a query or path parameter of an openAPIOperation.
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      map[string]any `json:"schema"`
+}
+
+// openAPIRequestBody describes the request body of an openAPIOperation.
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+// openAPIResponse describes a response of an openAPIOperation.
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+// openAPIMediaType holds the schema of a request or response body of a specific media type.
+type openAPIMediaType struct {
+	Schema map[string]any `json:"schema"`
+}
+
+// openAPIComponents holds the schemas referenced by an openAPIDocument, and the ways requests are authenticated.
+type openAPIComponents struct {
+	Schemas         map[string]map[string]any        `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+// openAPISecurityScheme describes a way requests are authenticated, see WithAuthToken.
+type openAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// openAPI returns the OpenAPI document of the API, built once.
+var openAPI = sync.OnceValue(func() openAPIDocument {
+	return newOpenAPIDocument(apiEndpoints(nil, nil))
+})
+
+// newOpenAPIDocument builds the OpenAPI document describing endpoints, where the schemas of request and response
+// bodies are generated from their Go types, see openAPISchemas.
+func newOpenAPIDocument(endpoints []apiEndpoint) openAPIDocument {
+	schemas := openAPISchemas{}
+	errorContent := map[string]openAPIMediaType{applicationJSON: {Schema: schemas.of(reflect.TypeOf(ErrorResponse{}))}}
+	doc := openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    openAPIInfo{Title: openAPITitle, Version: openAPIInfoVersion},
+		Paths:   make(map[string]map[string]openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: schemas,
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"bearer":      {Type: "http", Scheme: "bearer"},
+				"tokenHeader": {Type: "apiKey", In: "header", Name: tokenHeader},
+				"tokenQuery":  {Type: "apiKey", In: "query", Name: tokenParam},
+				"tokenCookie": {Type: "apiKey", In: "cookie", Name: tokenCookie},
+			},
+		},
+		// the empty requirement allows requests without a token, to servers that do not require one
+		Security: []map[string][]string{{"bearer": {}}, {"tokenHeader": {}}, {"tokenQuery": {}}, {"tokenCookie": {}}, {}},
+	}
+
+	for _, endpoint := range endpoints {
+		operation := openAPIOperation{
+			OperationID: endpoint.id,
+			Summary:     endpoint.summary,
+			Responses: map[string]openAPIResponse{
+				"200":     {Description: "Success", Content: schemas.content(endpoint.response)},
+				"default": {Description: "Error", Content: errorContent},
+			},
+		}
+		for _, param := range endpoint.params {
+			operation.Parameters = append(operation.Parameters, openAPIParameter{
+				Name:        param.name,
+				In:          param.in,
+				Description: param.description,
+				Required:    param.in == "path",
+				Schema:      map[string]any{"type": param.kind},
+			})
+		}
+		if endpoint.body != nil {
+			operation.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content:  map[string]openAPIMediaType{applicationJSON: {Schema: schemas.of(reflect.TypeOf(endpoint.body))}},
+			}
+		}
+		if endpoint.lifecycle {
+			operation.Parameters = append(operation.Parameters,
+				openAPIParameter{
+					Name:        waitParam,
+					In:          "query",
+					Description: "Whether to wait for an operation in progress to finish instead of failing with 409",
+					Schema:      map[string]any{"type": "boolean", "default": true},
+				},
+				openAPIParameter{
+					Name:        asyncParam,
+					In:          "query",
+					Description: "Whether to run the operation in the background and respond with a job immediately",
+					Schema:      map[string]any{"type": "boolean", "default": false},
+				},
+			)
+			operation.Responses["202"] = openAPIResponse{
+				Description: "The job running the operation in the background",
+				Content:     schemas.content(apiJSON(Job{})),
+			}
+			operation.Responses["409"] = openAPIResponse{
+				Description: "Another operation is in progress, with the running operation as details",
+				Content:     errorContent,
+			}
+		}
+
+		if doc.Paths[endpoint.path] == nil {
+			doc.Paths[endpoint.path] = make(map[string]openAPIOperation)
+		}
+		doc.Paths[endpoint.path][strings.ToLower(endpoint.method)] = operation
+	}
+	return doc
+}
+
+// openAPISchemas generates JSON schemas of Go types, as encoded by encoding/json.
+// Named struct types are added to it by name and referenced.
+type openAPISchemas map[string]map[string]any
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+)
+
+// content returns the media types of a response with their schemas.
+func (s openAPISchemas) content(content []apiContent) map[string]openAPIMediaType {
+	result := make(map[string]openAPIMediaType, len(content))
+	for _, c := range content {
+		switch {
+		case c.body != nil:
+			result[c.mediaType] = openAPIMediaType{Schema: s.of(reflect.TypeOf(c.body))}
+		case c.mediaType == applicationJSON:
+			result[c.mediaType] = openAPIMediaType{Schema: map[string]any{"type": "object"}}
+		case c.mediaType == applicationZip:
+			result[c.mediaType] = openAPIMediaType{Schema: map[string]any{"type": "string", "format": "binary"}}
+		default:
+			result[c.mediaType] = openAPIMediaType{Schema: map[string]any{"type": "string"}}
+		}
+	}
+	return result
+}
+
+// of returns the schema of t.
+func (s openAPISchemas) of(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]any{"type": "integer", "format": "int64", "description": "Duration in nanoseconds"}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.of(t.Elem())
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		if _, ok := s[t.Name()]; !ok {
+			// a placeholder is added first, so recursive types reference themselves instead of recursing forever
+			s[t.Name()] = map[string]any{}
+			s[t.Name()] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+// object returns the schema of the struct type t, where fields that are not omitted when empty are required.
+func (s openAPISchemas) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.of(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	result := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		result["required"] = required
+	}
+	return result
+}
+
+// getOpenAPIHandler handles requests to retrieve the OpenAPI document describing the API.
+type getOpenAPIHandler struct {
+	env *Environment
+}
+
+func (g getOpenAPIHandler) ServeHTTP(writer http.ResponseWriter, _ *http.Request) {
+	apiSuccess(g.env, writer, openAPI(), http.StatusOK)
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", &mockComponent{}))
+	assert.NoError(t, err)
+	router := mux.NewRouter()
+	registerRoutes(context.Background(), router, env)
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+
+	// every endpoint is documented, and every documented endpoint is routed
+	for _, endpoint := range apiEndpoints(env, nil) {
+		operation, ok := doc.Paths[endpoint.path][strings.ToLower(endpoint.method)]
+		if assert.True(t, ok, "%s %s is not documented", endpoint.method, endpoint.path) {
+			assert.Equal(t, endpoint.id, operation["operationId"])
+		}
+		var match mux.RouteMatch
+		assert.True(t, router.Match(httptest.NewRequest(endpoint.method, endpoint.path, nil), &match))
+		template, err := match.Route.GetPathTemplate()
+		assert.NoError(t, err)
+		assert.Equal(t, endpoint.path, template)
+	}
+	assert.Contains(t, doc.Paths, "/jobs/{id}")
+	assert.Contains(t, doc.Paths["/apply"]["post"]["responses"], "202")
+	assert.Contains(t, doc.Paths["/apply"]["post"]["responses"], "409")
+	assert.NotContains(t, doc.Paths["/status"]["get"]["responses"], "202")
+
+	startRequest := doc.Components.Schemas["PostStartRequest"]
+	assert.Equal(t, map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"component_id":      map[string]any{"type": "string"},
+			"with_dependencies": map[string]any{"type": "boolean"},
+			"cascade":           map[string]any{"type": "boolean"},
+		},
+		"required": []any{"cascade", "component_id", "with_dependencies"},
+	}, startRequest)
+
+	component := doc.Components.Schemas["GetStatusResponseComponent"]
+	properties := component["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/ComponentAlerts"}, properties["alerts"])
+	assert.Equal(t, map[string]any{"type": "object", "additionalProperties": map[string]any{}}, properties["config"])
+	assert.NotContains(t, component["required"], "alerts")
+
+	record := doc.Components.Schemas["OutputRecord"]["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, record["time"])
+	assert.Equal(t, map[string]any{"type": "string"}, record["level"])
+	assert.Contains(t, doc.Components.Schemas, "ErrorResponse")
+	assert.Contains(t, doc.Components.Schemas, "Job")
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RunningOperation describes an operation currently running on the Environment.
+//
+// Fields:
+// - Operation: The operation that is running.
+// - ComponentID: The component the operation was requested for, if any.
+// - StartedAt: The time the operation started running.
+type RunningOperation struct {
+	Operation   Operation `json:"operation"`
+	ComponentID string    `json:"component_id,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+}
+
+// operationLock makes sure only a single lifecycle operation runs on the Environment at any given time.
+// It also holds the observer of the running operation, which receives every event published during the operation.
+type operationLock struct {
+	lock     sync.Mutex
+	current  *RunningOperation
+	observer func(Event)
+	released chan struct{}
+}
+
+// newOperationLock creates a new instance of operationLock with no running operation.
+func newOperationLock() *operationLock {
+	return &operationLock{released: make(chan struct{})}
+}
+
+// acquire marks operation as the running operation, started now, and returns it. If another operation is already
+// running, acquire either waits for it to finish or for ctx to be done, or returns an ErrOperationInProgress if wait
+// is false.
+// observer, if not nil, receives all events published while the operation is running.
+func (o *operationLock) acquire(
+	ctx context.Context,
+	operation Operation,
+	componentID string,
+	observer func(Event),
+	wait bool,
+) (RunningOperation, error) {
+	for {
+		o.lock.Lock()
+		if o.current == nil {
+			o.current = &RunningOperation{Operation: operation, ComponentID: componentID, StartedAt: time.Now()}
+			o.observer = observer
+			running := *o.current
+			o.lock.Unlock()
+			return running, nil
+		}
+		current, released := *o.current, o.released
+		o.lock.Unlock()
+
+		if !wait {
+			return RunningOperation{}, ErrOperationInProgress{Running: current}
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return RunningOperation{}, fmt.Errorf(
+				"interrupted while waiting for %s to finish: %w",
+				current.Operation,
+				ctx.Err(),
+			)
+		}
+	}
+}
+
+// release marks the running operation as finished, allowing the next operation to run.
+func (o *operationLock) release() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.current = nil
+	o.observer = nil
+	close(o.released)
+	o.released = make(chan struct{})
+}
+
+// get returns the running operation, if any.
+func (o *operationLock) get() (RunningOperation, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.current == nil {
+		return RunningOperation{}, false
+	}
+	return *o.current, true
+}
+
+// observe passes event to the observer of the running operation, if any.
+func (o *operationLock) observe(event Event) {
+	o.lock.Lock()
+	observer := o.observer
+	o.lock.Unlock()
+	if observer != nil {
+		observer(event)
+	}
+}
+
+// ErrOperationInProgress is returned by lifecycle operations requested WithoutWaiting
+// while another operation is running on the Environment.
+type ErrOperationInProgress struct {
+	Running RunningOperation
+}
+
+func (e ErrOperationInProgress) Error() string {
+	operation := string(e.Running.Operation)
+	if e.Running.ComponentID != "" {
+		operation = fmt.Sprintf("%s of %s", operation, e.Running.ComponentID)
+	}
+	return fmt.Sprintf(
+		"operation %s is already in progress since %s",
+		operation,
+		e.Running.StartedAt.Local().Format(time.RFC3339),
+	)
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOperationSerialization(t *testing.T) {
+	component := &mockComponent{}
+	release := make(chan struct{})
+	started := make(chan struct{})
+	component.onStart = func() {
+		close(started)
+		<-release
+	}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+
+	_, ok := env.CurrentOperation()
+	assert.False(t, ok)
+
+	startDone := make(chan error)
+	go func() {
+		startDone <- env.StartAll(context.Background())
+	}()
+	<-started
+
+	running, ok := env.CurrentOperation()
+	assert.True(t, ok)
+	assert.Equal(t, OperationStartAll, running.Operation)
+	assert.False(t, running.StartedAt.IsZero())
+
+	status, err := env.Status(context.Background())
+	assert.NoError(t, err)
+	assert.NotNil(t, status.Operation)
+	assert.Equal(t, OperationStartAll, status.Operation.Operation)
+
+	// conflicting operations fail immediately when requested without waiting
+	_, err = env.StopComponent(context.Background(), "component", WithoutWaiting())
+	assert.Error(t, err)
+	var inProgressErr ErrOperationInProgress
+	assert.True(t, errors.As(err, &inProgressErr))
+	assert.Equal(t, running, inProgressErr.Running)
+	assert.Contains(t, err.Error(), "start_all is already in progress")
+	assert.False(t, component.stopCalled)
+
+	// waiting operations can be interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = env.StopAll(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, component.stopCalled)
+
+	// waiting operations run once the running operation finishes
+	stopDone := make(chan error)
+	go func() {
+		stopDone <- env.StopAll(context.Background())
+	}()
+	select {
+	case <-stopDone:
+		t.Fatal("stop all did not wait for start all to finish")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-startDone)
+	assert.NoError(t, <-stopDone)
+	assert.True(t, component.stopCalled)
+	assert.Equal(t, ComponentStatusStopped, component.status)
+
+	_, ok = env.CurrentOperation()
+	assert.False(t, ok)
+	status, err = env.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, status.Operation)
+}
+
+func TestAPIOperationInProgress(t *testing.T) {
+	component := &mockComponent{}
+	release := make(chan struct{})
+	started := make(chan struct{})
+	component.onStart = func() {
+		close(started)
+		<-release
+	}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+
+	startDone := make(chan error)
+	go func() {
+		_, err := env.StartComponent(context.Background(), "component")
+		startDone <- err
+	}()
+	<-started
+
+	req := httptest.NewRequest(http.MethodPost, "/stop_all?wait=false", strings.NewReader(`{}`))
+	req.Header.Set(contentType, applicationJSON)
+	res := httptest.NewRecorder()
+	postStopAllHandler{env: env}.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusConflict, res.Code)
+
+	var response struct {
+		Error   string           `json:"error"`
+		Details RunningOperation `json:"details"`
+	}
+	err = json.Unmarshal(res.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response.Error, "already in progress")
+	assert.Equal(t, OperationStartComponent, response.Details.Operation)
+	assert.Equal(t, "component", response.Details.ComponentID)
+
+	close(release)
+	assert.NoError(t, <-startDone)
+
+	req = httptest.NewRequest(http.MethodPost, "/stop_all?wait=false", strings.NewReader(`{}`))
+	req.Header.Set(contentType, applicationJSON)
+	res = httptest.NewRecorder()
+	postStopAllHandler{env: env}.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+}
@@ -4,6 +4,12 @@
 
 package envite
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // Option is a function type for configuring the Environment during initialization.
 type Option func(*Environment)
 
@@ -20,6 +26,8 @@ const (
 	LogLevelDebug
 	// LogLevelInfo represents the info log level.
 	LogLevelInfo
+	// LogLevelWarn represents the warn log level.
+	LogLevelWarn
 	// LogLevelError represents the error log level.
 	LogLevelError
 	// LogLevelFatal represents the fatal log level.
@@ -35,6 +43,8 @@ func (l LogLevel) String() string {
 		return "DEBUG"
 	case LogLevelInfo:
 		return "INFO"
+	case LogLevelWarn:
+		return "WARN"
 	case LogLevelError:
 		return "ERROR"
 	case LogLevelFatal:
@@ -43,9 +53,209 @@ func (l LogLevel) String() string {
 	return "INFO"
 }
 
+// MarshalText implements encoding.TextMarshaler, encoding a LogLevel as its lower case name.
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(strings.ToLower(l.String())), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, decoding a LogLevel from its name in any case.
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	for level := LogLevelTrace; level <= LogLevelFatal; level++ {
+		if strings.EqualFold(level.String(), string(text)) {
+			*l = level
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid log level %s", text)
+}
+
 // WithLogger is an Option function that sets the logger for the Environment.
 func WithLogger(logger Logger) Option {
 	return func(b *Environment) {
 		b.Logger = logger
 	}
 }
+
+// WithOutputLimits is an Option function that bounds the combined output of all components kept in memory.
+// Once exceeded, the oldest output is evicted, see WithOutputSpillFile to keep it on disk.
+// By default, all output is kept in memory.
+func WithOutputLimits(limits OutputLimits) Option {
+	return func(b *Environment) {
+		b.outputManager.limits = limits
+	}
+}
+
+// WithComponentOutputLimits is an Option function that bounds the output of the component identified by componentID
+// kept in memory, in addition to the limits of the combined output. Once exceeded, the oldest output of the component
+// is evicted, see WithOutputSpillFile to keep it on disk.
+func WithComponentOutputLimits(componentID string, limits OutputLimits) Option {
+	return func(b *Environment) {
+		b.outputManager.componentLimits[componentID] = limits
+	}
+}
+
+// WithOutputSpillFile is an Option function that appends output evicted from memory due to output limits
+// to the file at path, creating it if needed. Evicted output is buffered before it is written to the file,
+// see Environment.CloseOutput.
+func WithOutputSpillFile(path string) Option {
+	return func(b *Environment) {
+		b.outputManager.spillPath = path
+	}
+}
+
+// WithLogFiles is an Option function that mirrors all output to log files in a directory: a combined log file
+// holding the output of all components, and a log file per component. Log files are appended to if they exist,
+// and rotated once they reach a maximum size, see LogFiles.
+func WithLogFiles(config LogFiles) Option {
+	return func(b *Environment) {
+		b.outputManager.files = newLogFiles(config)
+	}
+}
+
+// FailurePolicy determines how the Environment handles a component failure while applying a state,
+// see Environment.Apply and Environment.StartAll.
+type FailurePolicy string
+
+const (
+	// FailurePolicyFailFast cancels all in-flight operations and returns as soon as any component fails.
+	// Components that were already started are left running. This is the default policy.
+	FailurePolicyFailFast FailurePolicy = "fail_fast"
+
+	// FailurePolicyContinue carries on operating on all components that do not depend on a failed component,
+	// and reports all failures once done.
+	FailurePolicyContinue FailurePolicy = "continue"
+
+	// FailurePolicyRollback cancels all in-flight operations as soon as any component fails, and then stops
+	// all components that were started by the failed operation, in reverse dependency order.
+	FailurePolicyRollback FailurePolicy = "rollback"
+)
+
+// ParseFailurePolicy parses the provided string value into a FailurePolicy.
+// It returns the parsed FailurePolicy or an error if the value is not a valid failure policy.
+func ParseFailurePolicy(value string) (FailurePolicy, error) {
+	switch FailurePolicy(value) {
+	case FailurePolicyFailFast, "":
+		return FailurePolicyFailFast, nil
+	case FailurePolicyContinue:
+		return FailurePolicyContinue, nil
+	case FailurePolicyRollback:
+		return FailurePolicyRollback, nil
+	}
+	return "", ErrInvalidFailurePolicy{v: value}
+}
+
+// WithFailurePolicy is an Option function that sets how the Environment handles component failures
+// while applying a state. The default is FailurePolicyFailFast.
+func WithFailurePolicy(policy FailurePolicy) Option {
+	return func(b *Environment) {
+		b.failurePolicy = policy
+	}
+}
+
+// LifecyclePolicy configures how the Environment operates on a single component.
+// The zero value applies no timeouts and no retries.
+type LifecyclePolicy struct {
+	// StartTimeout is the maximum duration a single attempt to start or restart the component may take.
+	StartTimeout time.Duration
+
+	// StopTimeout is the maximum duration stopping the component may take.
+	StopTimeout time.Duration
+
+	// Retries is the number of additional attempts to prepare, start or restart the component after a failure.
+	// An attempt that timed out is not retried if it is still running after the same timeout again.
+	Retries int
+
+	// RetryBackoff is the delay before the first retry. The delay doubles after each retry.
+	RetryBackoff time.Duration
+}
+
+// WithLifecyclePolicy is an Option function that sets the LifecyclePolicy of the component identified by componentID.
+// Start and stop timeouts are reported by Environment.Status as a ComponentStatusFailed with
+// ComponentFailureReasonStartTimeout or ComponentFailureReasonStopTimeout.
+func WithLifecyclePolicy(componentID string, policy LifecyclePolicy) Option {
+	return func(b *Environment) {
+		b.policies[componentID] = policy
+	}
+}
+
+// WithAlertRules is an Option function that adds rules raising alerts for lines of output of the component with the
+// given ID. Every line the component writes is matched against its rules, and lines that match are flagged with the
+// highest severity of the matching rules. The alerts raised since the component was last started are reported by
+// Environment.Status, which helps to notice components that keep running while logging errors.
+func WithAlertRules(componentID string, rules ...AlertRule) Option {
+	return func(b *Environment) {
+		b.alerts.rules[componentID] = append(b.alerts.rules[componentID], rules...)
+	}
+}
+
+// ErrInvalidFailurePolicy is an error type representing an invalid failure policy.
+type ErrInvalidFailurePolicy struct {
+	v string
+}
+
+func (e ErrInvalidFailurePolicy) Error() string {
+	return fmt.Sprintf("invalid failure policy %s", e.v)
+}
+
+// OperationOption is a function type for configuring a single lifecycle operation on the Environment,
+// such as applying state or starting a component.
+type OperationOption func(*operationOptions)
+
+// operationOptions holds the configuration of a single operation on the Environment.
+type operationOptions struct {
+	withDependencies bool
+	withDependents   bool
+	withoutWaiting   bool
+	cascade          bool
+	observer         func(Event)
+}
+
+// newOperationOptions applies the given options and returns the resulting configuration.
+func newOperationOptions(options []OperationOption) operationOptions {
+	var result operationOptions
+	for _, option := range options {
+		option(&result)
+	}
+	return result
+}
+
+// WithDependencies is an OperationOption that makes Environment.StartComponent start all components
+// the given component transitively depends on, in dependency order, before starting the component itself.
+func WithDependencies() OperationOption {
+	return func(o *operationOptions) {
+		o.withDependencies = true
+	}
+}
+
+// WithDependents is an OperationOption that makes Environment.StopComponent stop all components
+// that transitively depend on the given component, in reverse dependency order, before stopping the component itself.
+func WithDependents() OperationOption {
+	return func(o *operationOptions) {
+		o.withDependents = true
+	}
+}
+
+// WithCascade is an OperationOption that makes Environment.StartComponent and Environment.RestartComponent restart
+// all running components that transitively depend on the given component once it changed, in dependency order.
+// This is useful when dependents keep state that goes stale when the component changes, such as open connections.
+func WithCascade() OperationOption {
+	return func(o *operationOptions) {
+		o.cascade = true
+	}
+}
+
+// WithoutWaiting is an OperationOption that makes a lifecycle operation fail immediately with an
+// ErrOperationInProgress if another operation is already running on the Environment, instead of waiting for it
+// to finish.
+func WithoutWaiting() OperationOption {
+	return func(o *operationOptions) {
+		o.withoutWaiting = true
+	}
+}
+
+// withObserver is an OperationOption that passes every event published while the operation is running to observer.
+func withObserver(observer func(Event)) OperationOption {
+	return func(o *operationOptions) {
+		o.observer = observer
+	}
+}
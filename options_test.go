@@ -12,3 +12,25 @@ func TestLogLevel_String(t *testing.T) {
 	assert.Equal(t, LogLevelError.String(), "ERROR")
 	assert.Equal(t, LogLevelFatal.String(), "FATAL")
 }
+
+func TestParseFailurePolicy(t *testing.T) {
+	policy, err := ParseFailurePolicy("fail_fast")
+	assert.NoError(t, err)
+	assert.Equal(t, FailurePolicyFailFast, policy)
+
+	policy, err = ParseFailurePolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, FailurePolicyFailFast, policy)
+
+	policy, err = ParseFailurePolicy("continue")
+	assert.NoError(t, err)
+	assert.Equal(t, FailurePolicyContinue, policy)
+
+	policy, err = ParseFailurePolicy("rollback")
+	assert.NoError(t, err)
+	assert.Equal(t, FailurePolicyRollback, policy)
+
+	_, err = ParseFailurePolicy("invalid")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid failure policy")
+}
@@ -0,0 +1,230 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ComponentAction represents an action the Environment takes on a component as part of an operation.
+type ComponentAction string
+
+const (
+	// ComponentActionPrepare indicates the component is prepared before it is started.
+	ComponentActionPrepare ComponentAction = "prepare"
+
+	// ComponentActionStart indicates the component is started.
+	ComponentActionStart ComponentAction = "start"
+
+	// ComponentActionStop indicates the component is stopped.
+	ComponentActionStop ComponentAction = "stop"
+
+	// ComponentActionRestart indicates the component is restarted.
+	ComponentActionRestart ComponentAction = "restart"
+)
+
+// ComponentResult represents the result of an action taken on a component.
+type ComponentResult string
+
+const (
+	// ComponentResultSucceeded indicates the action completed successfully.
+	ComponentResultSucceeded ComponentResult = "succeeded"
+
+	// ComponentResultUnchanged indicates the action was not needed, e.g. starting a component that is already running.
+	ComponentResultUnchanged ComponentResult = "unchanged"
+
+	// ComponentResultFailed indicates the action returned an error.
+	ComponentResultFailed ComponentResult = "failed"
+
+	// ComponentResultCanceled indicates the action was interrupted since the operation was canceled,
+	// either by the caller or due to a failure of another component.
+	ComponentResultCanceled ComponentResult = "canceled"
+
+	// ComponentResultSkipped indicates the action was never attempted, since the operation stopped
+	// or one of the components it depends on failed.
+	ComponentResultSkipped ComponentResult = "skipped"
+
+	// ComponentResultRolledBack indicates the component was started and then stopped again as part of a rollback.
+	ComponentResultRolledBack ComponentResult = "rolled_back"
+
+	// ComponentResultRollbackFailed indicates the component was started, but could not be stopped as part of a rollback.
+	ComponentResultRollbackFailed ComponentResult = "rollback_failed"
+)
+
+// ComponentOutcome describes the outcome of a single component during an environment operation.
+type ComponentOutcome struct {
+	ID     string          `json:"id"`
+	Action ComponentAction `json:"action"`
+	Result ComponentResult `json:"result"`
+	Error  string          `json:"error,omitempty"`
+
+	err error
+}
+
+// Err returns the error the action returned, or nil if it did not fail.
+func (o ComponentOutcome) Err() error {
+	return o.err
+}
+
+// ApplyError is returned by Environment.Apply and Environment.StartAll when any component fails.
+// It lists the outcome of each component in the environment, ordered by their dependencies, so callers can
+// tell exactly which components were started or stopped, which failed, and which were never attempted.
+type ApplyError struct {
+	Policy     FailurePolicy      `json:"policy"`
+	Components []ComponentOutcome `json:"components"`
+}
+
+func (e *ApplyError) Error() string {
+	var messages []string
+	for _, c := range e.Components {
+		if c.Result == ComponentResultFailed || c.Result == ComponentResultRollbackFailed {
+			messages = append(messages, c.Error)
+		}
+	}
+	if len(messages) == 0 {
+		for _, c := range e.Components {
+			if c.err != nil {
+				messages = append(messages, c.Error)
+			}
+		}
+	}
+	return fmt.Sprintf("failed to apply state: %s", strings.Join(messages, "; "))
+}
+
+// Unwrap returns the errors of all components that did not complete successfully.
+func (e *ApplyError) Unwrap() []error {
+	var result []error
+	for _, c := range e.Components {
+		if c.err != nil {
+			result = append(result, c.err)
+		}
+	}
+	return result
+}
+
+// Failed returns the outcomes of all components that failed.
+func (e *ApplyError) Failed() []ComponentOutcome {
+	var result []ComponentOutcome
+	for _, c := range e.Components {
+		if c.Result == ComponentResultFailed || c.Result == ComponentResultRollbackFailed {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// operationOutcomes collects the outcome of each component during a single environment operation.
+// It is safe for concurrent use.
+type operationOutcomes struct {
+	lock     sync.Mutex
+	outcomes map[string]ComponentOutcome
+}
+
+// newOperationOutcomes creates a new empty operationOutcomes.
+func newOperationOutcomes() *operationOutcomes {
+	return &operationOutcomes{outcomes: make(map[string]ComponentOutcome)}
+}
+
+// record stores the outcome of an action taken on a component, replacing any previous outcome of that component.
+func (o *operationOutcomes) record(id string, action ComponentAction, result ComponentResult, err error) {
+	outcome := ComponentOutcome{ID: id, Action: action, Result: result, err: err}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.outcomes[id] = outcome
+}
+
+// get returns the latest outcome recorded for a component.
+func (o *operationOutcomes) get(id string) (ComponentOutcome, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	outcome, ok := o.outcomes[id]
+	return outcome, ok
+}
+
+// ids returns the IDs of all components whose latest outcome matches action and any of the given results.
+func (o *operationOutcomes) ids(action ComponentAction, results ...ComponentResult) map[string]struct{} {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	result := make(map[string]struct{})
+	for id, outcome := range o.outcomes {
+		if outcome.Action != action {
+			continue
+		}
+		for _, r := range results {
+			if outcome.Result == r {
+				result[id] = struct{}{}
+				break
+			}
+		}
+	}
+	return result
+}
+
+// failed reports whether any component did not complete its action successfully.
+func (o *operationOutcomes) failed() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for _, outcome := range o.outcomes {
+		if outcome.err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the outcome of every component in the graph, ordered by their dependencies.
+// final returns the last action the operation was meant to take on each component. Components that never
+// reached it without failing on an earlier action, e.g. prepared but never started, are reported as skipped.
+func (o *operationOutcomes) list(graph *dependencyGraph, final func(id string) ComponentAction) []ComponentOutcome {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	result := make([]ComponentOutcome, 0, len(graph.ids))
+	for _, layer := range graph.layers {
+		for _, id := range layer {
+			action := final(id)
+			outcome, ok := o.outcomes[id]
+			if !ok || (outcome.err == nil && outcome.Action != action) {
+				outcome = ComponentOutcome{ID: id, Action: action, Result: ComponentResultSkipped}
+			}
+			result = append(result, outcome)
+		}
+	}
+	return result
+}
+
+// Plan describes the actions Environment.Apply would take, as returned by Environment.Plan.
+// Components are ordered by their dependencies.
+type Plan struct {
+	Components []PlannedComponent `json:"components"`
+}
+
+// Changes returns the planned components that would be changed, omitting the ones that require no action.
+func (p Plan) Changes() []PlannedComponent {
+	var result []PlannedComponent
+	for _, c := range p.Components {
+		if len(c.Actions) > 0 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// PlannedComponent describes the actions Environment.Apply would take on a single component.
+//
+// Fields:
+// - ID: The ID of the component.
+// - Status: The current status of the component.
+// - Actions: The actions that would be taken on the component, in order. Empty if no action is needed.
+type PlannedComponent struct {
+	ID      string            `json:"id"`
+	Status  ComponentStatus   `json:"status"`
+	Actions []ComponentAction `json:"actions"`
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"fmt"
+	"time"
+)
+
+// OutputStream represents the stream a line of output was written to.
+type OutputStream string
+
+const (
+	// OutputStreamStdout represents the standard output stream. It is the default for output written via a Writer.
+	OutputStreamStdout OutputStream = "stdout"
+
+	// OutputStreamStderr represents the standard error stream.
+	OutputStreamStderr OutputStream = "stderr"
+)
+
+// OutputRecord is a single line of output written by a component.
+//
+// Fields:
+// - ComponentID: The component that wrote the line.
+// - Time: The time the line was written.
+// - Stream: The stream the line was written to.
+// - Level: The severity of the line.
+// - Text: The text of the line, without a trailing newline and without ANSI escape codes.
+// - Spans: The colored or bold parts of the text, if any, see Render.
+// - Attributes: Additional structured information about the line, if any.
+// - Alert: The severity of the alert the line raised by matching an AlertRule of its component, if any.
+type OutputRecord struct {
+	ComponentID string         `json:"component_id"`
+	Time        time.Time      `json:"time"`
+	Stream      OutputStream   `json:"stream"`
+	Level       LogLevel       `json:"level"`
+	Text        string         `json:"text"`
+	Spans       []OutputSpan   `json:"spans,omitempty"`
+	Attributes  map[string]any `json:"attributes,omitempty"`
+	Alert       AlertSeverity  `json:"alert,omitempty"`
+}
+
+// Render returns the text of the record rendered for a consumer, with its spans applied.
+func (r OutputRecord) Render(rendering OutputRendering) string {
+	return renderText(r.Text, r.Spans, rendering)
+}
+
+// rendered returns a copy of the record whose text is rendered for a consumer. Spans are kept only for plain
+// rendering, since their offsets do not apply to text rendered in any other way.
+func (r OutputRecord) rendered(rendering OutputRendering) OutputRecord {
+	if rendering == OutputRenderingPlain {
+		return r
+	}
+	r.Text = r.Render(rendering)
+	r.Spans = nil
+	return r
+}
+
+// legacy formats the record as a line of the tagged text format served by the /output API before records
+// were introduced. Lines are highlighted as this format has no notion of streams or alerts, see highlightedSpans.
+func (r OutputRecord) legacy(rendering OutputRendering) []byte {
+	text := renderText(r.Text, r.highlightedSpans(), rendering)
+	return []byte(fmt.Sprintf("<component>%s<time>%s<msg>%s\n", r.ComponentID, r.Time.Local().Format(timeFormat), text))
+}
+
+// highlightedSpans returns the spans of the record for consumers that show text only: lines that raised an alert are
+// bold and colored by their severity, and uncolored lines written to stderr are colored red.
+func (r OutputRecord) highlightedSpans() []OutputSpan {
+	switch {
+	case r.Alert == AlertSeverityError:
+		return []OutputSpan{{Start: 0, End: len(r.Text), Color: "red", Bold: true}}
+	case r.Alert == AlertSeverityWarning:
+		return []OutputSpan{{Start: 0, End: len(r.Text), Color: "yellow", Bold: true}}
+	case r.Stream == OutputStreamStderr && len(r.Spans) == 0:
+		return []OutputSpan{{Start: 0, End: len(r.Text), Color: "red"}}
+	}
+	return r.Spans
+}
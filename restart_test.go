@@ -0,0 +1,247 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type restartableComponent struct {
+	*mockComponent
+	restarts    int
+	failRestart bool
+}
+
+func (r *restartableComponent) Restart(context.Context) error {
+	if r.failRestart {
+		return errors.New("restart error")
+	}
+	r.restarts++
+	r.status = ComponentStatusRunning
+	return nil
+}
+
+// orderedComponent records the order of calls to its lifecycle methods.
+type orderedComponent struct {
+	*mockComponent
+	calls []string
+}
+
+func (o *orderedComponent) Prepare(ctx context.Context) error {
+	o.calls = append(o.calls, "prepare")
+	return o.mockComponent.Prepare(ctx)
+}
+
+func (o *orderedComponent) Start(ctx context.Context) error {
+	o.calls = append(o.calls, "start")
+	return o.mockComponent.Start(ctx)
+}
+
+func (o *orderedComponent) Stop(ctx context.Context) error {
+	o.calls = append(o.calls, "stop")
+	return o.mockComponent.Stop(ctx)
+}
+
+func TestRestartComponentPrepares(t *testing.T) {
+	component := &orderedComponent{mockComponent: &mockComponent{}}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+
+	_, err = env.StartComponent(context.Background(), "component")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prepare", "start"}, component.calls)
+
+	component.calls = nil
+	restarted, err := env.RestartComponent(context.Background(), "component")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"component"}, restarted)
+	assert.Equal(t, []string{"stop", "prepare", "start"}, component.calls)
+
+	component.calls = nil
+	assert.NoError(t, env.RestartAll(context.Background()))
+	assert.Equal(t, []string{"stop", "prepare", "start"}, component.calls)
+}
+
+func TestRestartComponent(t *testing.T) {
+	restartable := &restartableComponent{mockComponent: &mockComponent{}}
+	plain := &mockComponent{}
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().
+			AddComponent("restartable", restartable).
+			AddComponent("plain", plain, "restartable"),
+	)
+	assert.NoError(t, err)
+
+	// components that are not running are prepared and started
+	restarted, err := env.RestartComponent(context.Background(), "restartable")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"restartable"}, restarted)
+	assert.True(t, restartable.prepareCalled)
+	assert.True(t, restartable.startCalled)
+	assert.Equal(t, 0, restartable.restarts)
+
+	// running restarters are restarted in place
+	subscription := env.Subscribe()
+	defer func() {
+		_ = subscription.Close()
+	}()
+	restartable.prepareCalled = false
+	restartable.startCalled = false
+	restarted, err = env.RestartComponent(context.Background(), "restartable")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"restartable"}, restarted)
+	assert.Equal(t, 1, restartable.restarts)
+	assert.False(t, restartable.prepareCalled)
+	assert.False(t, restartable.startCalled)
+	assert.False(t, restartable.stopCalled)
+	var types []EventType
+	for len(subscription.Chan()) > 0 {
+		types = append(types, (<-subscription.Chan()).Type)
+	}
+	assert.Equal(t, []EventType{
+		EventOperationStarted,
+		EventComponentRestarting,
+		EventComponentRunning,
+		EventOperationFinished,
+	}, types)
+
+	// any other running component is stopped and started
+	_, err = env.StartComponent(context.Background(), "plain")
+	assert.NoError(t, err)
+	plain.startCalled = false
+	restarted, err = env.RestartComponent(context.Background(), "plain")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"plain"}, restarted)
+	assert.True(t, plain.stopCalled)
+	assert.True(t, plain.startCalled)
+	assert.Equal(t, ComponentStatusRunning, plain.status)
+
+	// restart all restarts every component
+	err = env.RestartAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, restartable.restarts)
+
+	// failures are reported in the status of the component
+	restartable.failRestart = true
+	restarted, err = env.RestartComponent(context.Background(), "restartable")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "restart error")
+	assert.Empty(t, restarted)
+	status, err := env.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ComponentStatusFailed, status.Components[0][0].Status)
+	assert.Equal(t, ComponentFailureReasonError, status.Components[0][0].FailureReason)
+
+	_, err = env.RestartComponent(context.Background(), "invalid")
+	assert.Error(t, err)
+}
+
+func TestCascade(t *testing.T) {
+	db := &restartableComponent{mockComponent: &mockComponent{}}
+	cache := &restartableComponent{mockComponent: &mockComponent{}}
+	service := &mockComponent{}
+	worker := &mockComponent{}
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().
+			AddComponent("db", db).
+			AddComponent("cache", cache, "db").
+			AddComponent("service", service, "cache").
+			AddComponent("worker", worker, "db"),
+	)
+	assert.NoError(t, err)
+	_, err = env.StartComponent(context.Background(), "service", WithDependencies())
+	assert.NoError(t, err)
+
+	plan, err := env.CascadePlan(context.Background(), "db")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cache", "service"}, plan)
+
+	subscription := env.Subscribe()
+	defer func() {
+		_ = subscription.Close()
+	}()
+	receive := func() []string {
+		var result []string
+		for len(subscription.Chan()) > 0 {
+			event := <-subscription.Chan()
+			if event.Type == EventCascadePlanned {
+				result = append(result, fmt.Sprintf("%s:%s:%v", event.Type, event.ComponentID, event.Components))
+			} else if event.ComponentID != "" && event.Type != EventOperationStarted && event.Type != EventOperationFinished {
+				result = append(result, fmt.Sprintf("%s:%s", event.Type, event.ComponentID))
+			}
+		}
+		return result
+	}
+
+	// restarting cascades to running dependents only, after reporting the plan
+	restarted, err := env.RestartComponent(context.Background(), "db", WithCascade())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db", "cache", "service"}, restarted)
+	assert.Equal(t, 1, db.restarts)
+	assert.Equal(t, 1, cache.restarts)
+	assert.True(t, service.stopCalled)
+	assert.Equal(t, ComponentStatusRunning, service.status)
+	assert.False(t, worker.startCalled)
+	assert.Equal(t, []string{
+		"component_restarting:db",
+		"component_running:db",
+		"cascade_planned:db:[cache service]",
+		"component_restarting:cache",
+		"component_running:cache",
+		"component_stopped:service",
+		"component_prepared:service",
+		"component_starting:service",
+		"component_running:service",
+	}, receive())
+
+	// starting a component that is already running does not cascade
+	restarted, err = env.StartComponent(context.Background(), "db", WithCascade())
+	assert.NoError(t, err)
+	assert.Empty(t, restarted)
+	assert.Equal(t, 1, cache.restarts)
+
+	// starting a component cascades once it started
+	_, err = env.StopComponent(context.Background(), "db")
+	assert.NoError(t, err)
+	restarted, err = env.StartComponent(context.Background(), "db", WithCascade())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db", "cache", "service"}, restarted)
+	assert.Equal(t, 2, cache.restarts)
+	assert.False(t, worker.startCalled)
+
+	// without cascade, dependents are not restarted
+	restarted, err = env.RestartComponent(context.Background(), "db")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db"}, restarted)
+	assert.Equal(t, 2, cache.restarts)
+
+	_, err = env.CascadePlan(context.Background(), "invalid")
+	assert.Error(t, err)
+}
+
+func TestAPIRestartComponent(t *testing.T) {
+	component := &restartableComponent{mockComponent: &mockComponent{}}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+	err = env.StartAll(context.Background())
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/restart_component", strings.NewReader(`{"component_id": "component"}`))
+	req.Header.Set(contentType, applicationJSON)
+	res := httptest.NewRecorder()
+	postRestartHandler{env: env}.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"restarted": ["component"]}`, res.Body.String())
+	assert.Equal(t, 1, component.restarts)
+}
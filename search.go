@@ -0,0 +1,70 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import "container/list"
+
+// OutputMatch is a line of output found by Environment.SearchOutput.
+//
+// Fields:
+// - Record: The line that matched the search.
+// - Before: Lines written by the same component right before the matching line, oldest first.
+// - After: Lines written by the same component right after the matching line, oldest first.
+type OutputMatch struct {
+	Record OutputRecord   `json:"record"`
+	Before []OutputRecord `json:"before"`
+	After  []OutputRecord `json:"after"`
+}
+
+// search returns the lines of output kept in memory that match options, each with up to contextLines lines
+// of the same component before and after it. Context lines are not filtered.
+func (o *outputManager) search(contextLines int, options ...ReaderOption) []OutputMatch {
+	opts := newReaderOptions(options)
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	// entries of a component appear in the same order in o.entries and in its elements,
+	// so counting them reveals the position of each entry within its component
+	positions := make(map[string]int, len(o.components))
+	matches := []OutputMatch{}
+	for element := o.entries.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*outputEntry)
+		position := positions[entry.record.ComponentID]
+		positions[entry.record.ComponentID]++
+		if !opts.matches(entry.record) {
+			continue
+		}
+
+		elements := o.components[entry.record.ComponentID].elements
+		start := position - contextLines
+		if start < 0 {
+			start = 0
+		}
+		end := position + 1 + contextLines
+		if end > len(elements) {
+			end = len(elements)
+		}
+		matches = append(matches, OutputMatch{
+			Record: entry.record,
+			Before: recordsOf(elements[start:position]),
+			After:  recordsOf(elements[position+1 : end]),
+		})
+	}
+	if opts.lastLines > 0 && len(matches) > opts.lastLines {
+		matches = matches[len(matches)-opts.lastLines:]
+	}
+
+	return matches
+}
+
+// recordsOf returns the records of the given outputManager list elements.
+func recordsOf(elements []*list.Element) []OutputRecord {
+	result := make([]OutputRecord, 0, len(elements))
+	for _, element := range elements {
+		result = append(result, element.Value.(*outputEntry).record)
+	}
+	return result
+}
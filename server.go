@@ -6,45 +6,389 @@ package envite
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	// defaultHost is the host the Server binds to unless configured otherwise using WithHost.
+	defaultHost = "localhost"
+
+	// tokenHeader, tokenParam and tokenCookie carry the auth token of a request, along with the Authorization header.
+	tokenHeader = "token"
+	tokenParam  = "token"
+	tokenCookie = "envite_token"
+
+	authorization = "Authorization"
+	bearerPrefix  = "Bearer "
+	origin        = "Origin"
+	vary          = "Vary"
 )
 
 // Server is an HTTP server, serving UI and API requests to manage the Environment when running in ExecutionModeDaemon.
+//
+// By default, the Server serves plain HTTP on a TCP port of localhost, does not require authentication, and serves
+// cross-origin browser requests only from origins allowed using WithAllowedOrigins. Requests to host names it does not
+// know are rejected, see WithHost. See ServerOption for other options.
 type Server struct {
-	addr       string
-	env        *Environment
-	httpServer *http.Server
-	errHandler func(string)
+	addr           string
+	host           string
+	port           string
+	token          string
+	allowedOrigins map[string]bool
+	certFile       string
+	keyFile        string
+	selfSigned     bool
+	socketPath     string
+	socketMode     os.FileMode
+	exitPolicy     ExitPolicy
+	env            *Environment
+	httpServer     *http.Server
+	cancel         context.CancelFunc
+	errHandler     func(string)
 }
 
-// NewServer creates a new Server instance for the given Environment.
-func NewServer(port string, env *Environment) *Server {
-	if len(port) > 0 && port[0] != ':' {
-		port = ":" + port
+// ServerOption is a function type for configuring a Server.
+type ServerOption func(*Server)
+
+// WithHost is a ServerOption that sets the host the Server binds to, instead of localhost.
+// An empty host binds to all network interfaces.
+func WithHost(host string) ServerOption {
+	return func(s *Server) {
+		s.host = host
+	}
+}
+
+// WithAuthToken is a ServerOption that requires every request to carry token, either as a bearer token
+// in the Authorization header, in a token header, or in a token query parameter. A request authenticated using
+// the query parameter also receives a cookie authenticating subsequent requests, so the UI link may embed the token.
+func WithAuthToken(token string) ServerOption {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithGeneratedAuthToken is a ServerOption like WithAuthToken, where the token is randomly generated.
+// The token is available via Server.Token, and embedded in Server.URL.
+func WithGeneratedAuthToken() ServerOption {
+	return func(s *Server) {
+		s.token = generateToken()
 	}
+}
 
-	s := &Server{addr: port, env: env}
+// WithAllowedOrigins is a ServerOption that allows browser requests from the given origins,
+// such as http://localhost:3000, in addition to same origin requests. The origin * allows any origin.
+// Browser requests from any other origin are rejected. Requests to the hosts of the given origins are allowed, e.g.
+// to reach a Server bound to all network interfaces using the host name of the machine.
+func WithAllowedOrigins(origins ...string) ServerOption {
+	return func(s *Server) {
+		for _, o := range origins {
+			s.allowedOrigins[strings.TrimSuffix(o, "/")] = true
+		}
+	}
+}
 
+// WithTLS is a ServerOption that serves HTTPS using the certificate and private key in the given PEM encoded files.
+func WithTLS(certFile, keyFile string) ServerOption {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// WithSelfSignedTLS is a ServerOption that serves HTTPS using a self-signed certificate generated when the Server
+// starts, valid for localhost and the host set using WithHost. Clients need to skip verifying the certificate.
+func WithSelfSignedTLS() ServerOption {
+	return func(s *Server) {
+		s.selfSigned = true
+	}
+}
+
+// WithUnixSocket is a ServerOption that makes the Server listen on a Unix domain socket at path instead of a TCP port.
+// The socket file is created with the given permissions, where zero means 0600 to allow only the current user,
+// and removed once the Server is closed. See NewUnixSocketClient for sending requests to the socket.
+func WithUnixSocket(path string, mode os.FileMode) ServerOption {
+	return func(s *Server) {
+		if mode == 0 {
+			mode = 0o600
+		}
+		s.socketPath = path
+		s.socketMode = mode
+	}
+}
+
+// NewServer creates a new Server instance for the given Environment.
+func NewServer(port string, env *Environment, options ...ServerOption) *Server {
+	s := &Server{
+		host:           defaultHost,
+		port:           strings.TrimPrefix(port, ":"),
+		allowedOrigins: make(map[string]bool),
+		exitPolicy:     ExitPolicyLeave,
+		env:            env,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.addr = net.JoinHostPort(s.host, s.port)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
 	router := mux.NewRouter()
-	registerRoutes(router, env)
-	s.httpServer = &http.Server{Addr: port, Handler: router}
+	registerRoutes(ctx, router, env)
+	s.httpServer = &http.Server{
+		Addr:        s.addr,
+		Handler:     s.handler(router),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
 
 	return s
 }
 
-// Start starts the HTTP server.
+// Token returns the token requests must carry, or an empty string if the Server does not require authentication.
+func (s *Server) Token() string {
+	return s.token
+}
+
+// URL returns the URL of the UI served by the Server, including its token if it requires authentication.
+// If the Server listens on a Unix domain socket, the URL has a unix scheme and holds the path of the socket.
+func (s *Server) URL() string {
+	if s.socketPath != "" {
+		return (&url.URL{Scheme: "unix", Path: s.socketPath}).String()
+	}
+
+	host := s.host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = defaultHost
+	}
+	scheme := "http"
+	if s.tls() {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: net.JoinHostPort(host, s.port), Path: "/"}
+	if s.token != "" {
+		u.RawQuery = url.Values{tokenParam: []string{s.token}}.Encode()
+	}
+	return u.String()
+}
+
+// Start starts the HTTP server, and blocks until it is closed.
 func (s *Server) Start() error {
-	err := s.httpServer.ListenAndServe()
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	err = s.httpServer.Serve(listener)
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 	return nil
 }
 
-// Close gracefully shuts down the HTTP server.
+// listen creates the listener of the Server, on a TCP port or on a Unix domain socket, serving TLS if configured.
+func (s *Server) listen() (net.Listener, error) {
+	config, err := s.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var listener net.Listener
+	if s.socketPath != "" {
+		listener, err = listenUnixSocket(s.socketPath, s.socketMode)
+	} else {
+		listener, err = net.Listen("tcp", s.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if config != nil {
+		listener = tls.NewListener(listener, config)
+	}
+	return listener, nil
+}
+
+// tls reports whether the Server serves HTTPS.
+func (s *Server) tls() bool {
+	return s.certFile != "" || s.keyFile != "" || s.selfSigned
+}
+
+// listenUnixSocket listens on a Unix domain socket at path, replacing a stale socket file left by a previous process.
+func listenUnixSocket(path string, mode os.FileMode) (net.Listener, error) {
+	info, err := os.Lstat(path)
+	if err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("could not listen on %s: file exists and is not a socket", path)
+		}
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("could not listen on %s: socket is in use", path)
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not remove stale socket %s: %w", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(path, mode)
+	if err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("could not set permissions of socket %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// NewUnixSocketClient returns an HTTP client sending all requests to the Unix domain socket at path, such as
+// the socket of a Server listening WithUnixSocket. The host of request URLs is ignored, e.g. http://envite/status.
+func NewUnixSocketClient(path string) *http.Client {
+	dialer := &net.Dialer{}
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+// Close gracefully shuts down the HTTP server, and then applies the exit policy of the Server to the Environment,
+// see WithExitPolicy. The contexts of in-flight requests and asynchronous jobs are canceled, so operations they run
+// return early, and streams of output and events end. Finally, the output files of the Environment are closed,
+// see Environment.CloseOutput.
 func (s *Server) Close() error {
 	s.httpServer.SetKeepAlivesEnabled(false)
-	return s.httpServer.Shutdown(context.Background())
+	s.cancel()
+	err := s.httpServer.Shutdown(context.Background())
+	if err != nil {
+		return err
+	}
+
+	err = s.exit(context.Background())
+	closeErr := s.env.CloseOutput()
+	if err != nil {
+		return fmt.Errorf("could not apply exit policy %s: %w", s.exitPolicy, err)
+	}
+	return closeErr
+}
+
+// handler wraps next, rejecting requests to hosts or from origins that are not allowed and requests without
+// a valid token. CORS preflight requests carry no credentials, so they are not authenticated.
+func (s *Server) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !s.hostAllowed(request.Host) {
+			apiError(s.env, writer, fmt.Sprintf("host %s is not allowed", request.Host), http.StatusForbidden)
+			return
+		}
+		if requestOrigin := request.Header.Get(origin); requestOrigin != "" {
+			if !s.originAllowed(requestOrigin, request) {
+				apiError(s.env, writer, fmt.Sprintf("origin %s is not allowed", requestOrigin), http.StatusForbidden)
+				return
+			}
+			if s.allowedOrigins[accessControlAny] {
+				writer.Header().Set(accessControl, accessControlAny)
+			} else {
+				writer.Header().Set(accessControl, requestOrigin)
+				writer.Header().Add(vary, origin)
+			}
+		}
+
+		if s.token == "" || request.Method == http.MethodOptions {
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		if s.validToken(request.URL.Query().Get(tokenParam)) {
+			http.SetCookie(writer, &http.Cookie{
+				Name:     tokenCookie,
+				Value:    s.token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   s.tls(),
+				SameSite: http.SameSiteStrictMode,
+			})
+			next.ServeHTTP(writer, request)
+			return
+		}
+		if !s.authenticated(request) {
+			apiError(s.env, writer, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// hostAllowed reports whether a request to host, the Host header of the request, may be served. It protects against
+// DNS rebinding, where a page of another site resolves its own host name to the Server, so its requests pass as same
+// origin requests. Allowed hosts are localhost, the host set using WithHost, the hosts of the origins allowed using
+// WithAllowedOrigins and IP addresses, which cannot be rebound. Requests to a Unix domain socket are always allowed.
+func (s *Server) hostAllowed(host string) bool {
+	if s.socketPath != "" || host == "" {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if host == defaultHost || strings.HasSuffix(host, "."+defaultHost) || net.ParseIP(host) != nil ||
+		host == strings.ToLower(s.host) {
+		return true
+	}
+	for allowed := range s.allowedOrigins {
+		u, err := url.Parse(allowed)
+		if err == nil && strings.ToLower(u.Hostname()) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// originAllowed reports whether a browser request from requestOrigin may be served.
+// Same origin requests are always allowed, since hostAllowed verified the host they are sent to.
+func (s *Server) originAllowed(requestOrigin string, request *http.Request) bool {
+	if s.allowedOrigins[accessControlAny] || s.allowedOrigins[requestOrigin] {
+		return true
+	}
+	u, err := url.Parse(requestOrigin)
+	return err == nil && u.Host == request.Host
+}
+
+// authenticated reports whether request carries a valid token in one of its headers or in a cookie.
+func (s *Server) authenticated(request *http.Request) bool {
+	if value := request.Header.Get(authorization); strings.HasPrefix(value, bearerPrefix) {
+		return s.validToken(strings.TrimPrefix(value, bearerPrefix))
+	}
+	if value := request.Header.Get(tokenHeader); value != "" {
+		return s.validToken(value)
+	}
+	cookie, err := request.Cookie(tokenCookie)
+	return err == nil && s.validToken(cookie.Value)
+}
+
+// validToken reports whether token is the token of the Server, in constant time.
+func (s *Server) validToken(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// generateToken returns a random token.
+func generateToken() string {
+	data := make([]byte, 32)
+	_, err := rand.Read(data)
+	if err != nil {
+		// the operating system provides no randomness, so no token would be safe to use
+		panic(fmt.Sprintf("could not generate auth token: %v", err))
+	}
+	return hex.EncodeToString(data)
 }
@@ -1,17 +1,217 @@
 package envite
 
 import (
+	"crypto/tls"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewServer(t *testing.T) {
 	s := NewServer(":8081", nil)
 	assert.NotNil(t, s)
-	assert.Equal(t, ":8081", s.httpServer.Addr)
+	assert.Equal(t, "localhost:8081", s.httpServer.Addr)
+	assert.Equal(t, "http://localhost:8081/", s.URL())
+	assert.Empty(t, s.Token())
 
 	// Assert addr validation
 	s = NewServer("8081", nil)
 	assert.NotNil(t, s)
+	assert.Equal(t, "localhost:8081", s.httpServer.Addr)
+
+	s = NewServer("8081", nil, WithHost(""))
 	assert.Equal(t, ":8081", s.httpServer.Addr)
+	assert.Equal(t, "http://localhost:8081/", s.URL())
+
+	s = NewServer("8081", nil, WithGeneratedAuthToken())
+	assert.Len(t, s.Token(), 64)
+	assert.Equal(t, "http://localhost:8081/?token="+s.Token(), s.URL())
+	assert.NotEqual(t, s.Token(), NewServer("8081", nil, WithGeneratedAuthToken()).Token())
+}
+
+func TestServerAuth(t *testing.T) {
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", &mockComponent{}))
+	assert.NoError(t, err)
+	s := NewServer("8081", env, WithAuthToken("secret"))
+
+	serve := func(request *http.Request) *httptest.ResponseRecorder {
+		request.Host = "localhost:8081"
+		response := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(response, request)
+		return response
+	}
+
+	res := serve(httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	assert.Equal(t, http.StatusUnauthorized, serve(req).Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	assert.Equal(t, http.StatusOK, serve(req).Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.Header.Set("token", "secret")
+	assert.Equal(t, http.StatusOK, serve(req).Code)
+
+	// the token query parameter sets a cookie authenticating later requests
+	res = serve(httptest.NewRequest(http.MethodGet, "/status?token=secret", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	cookies := res.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, "envite_token", cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+
+	req = httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.AddCookie(cookies[0])
+	assert.Equal(t, http.StatusOK, serve(req).Code)
+
+	// preflight requests carry no credentials
+	assert.Equal(t, http.StatusOK, serve(httptest.NewRequest(http.MethodOptions, "/stop_all", nil)).Code)
+}
+
+func TestServerOrigins(t *testing.T) {
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", &mockComponent{}))
+	assert.NoError(t, err)
+
+	serve := func(s *Server, requestOrigin string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:8081/status", nil)
+		if requestOrigin != "" {
+			request.Header.Set("Origin", requestOrigin)
+		}
+		response := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(response, request)
+		return response
+	}
+
+	s := NewServer("8081", env, WithAllowedOrigins("http://localhost:3000/"))
+	res := serve(s, "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Empty(t, res.Header().Get("Access-Control-Allow-Origin"))
+
+	res = serve(s, "http://localhost:8081")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "http://localhost:8081", res.Header().Get("Access-Control-Allow-Origin"))
+
+	res = serve(s, "http://localhost:3000")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "http://localhost:3000", res.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", res.Header().Get("Vary"))
+
+	res = serve(s, "https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, res.Code)
+	assert.Empty(t, res.Header().Get("Access-Control-Allow-Origin"))
+
+	res = serve(NewServer("8081", env, WithAllowedOrigins("*")), "https://any.example.com")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "*", res.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestServerHosts(t *testing.T) {
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", &mockComponent{}))
+	assert.NoError(t, err)
+
+	serve := func(s *Server, host string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "http://"+host+"/status", nil)
+		request.Header.Set("Origin", "http://"+host)
+		response := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// a DNS rebinding page forges a same origin request to its own host
+	s := NewServer("8081", env, WithAllowedOrigins("http://dev.example.com:3000"))
+	res := serve(s, "rebind.example.com:8081")
+	assert.Equal(t, http.StatusForbidden, res.Code)
+	assert.Empty(t, res.Header().Get("Access-Control-Allow-Origin"))
+
+	for _, host := range []string{"localhost:8081", "127.0.0.1:8081", "[::1]:8081", "dev.example.com:8081"} {
+		res = serve(s, host)
+		assert.Equal(t, http.StatusOK, res.Code, host)
+	}
+
+	res = serve(NewServer("8081", env, WithHost("devbox.example.com")), "DevBox.example.com:8081")
+	assert.Equal(t, http.StatusOK, res.Code)
+}
+
+func TestServerTLS(t *testing.T) {
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", &mockComponent{}))
+	assert.NoError(t, err)
+
+	s := NewServer("0", env, WithSelfSignedTLS())
+	assert.Equal(t, "https://localhost:0/", s.URL())
+	listener, err := s.listen()
+	assert.NoError(t, err)
+	go func() {
+		_ = s.httpServer.Serve(listener)
+	}()
+	defer func() {
+		_ = s.Close()
+	}()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	res, err := client.Get("https://" + listener.Addr().String() + "/status")
+	assert.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, []string{"localhost"}, res.TLS.PeerCertificates[0].DNSNames)
+
+	// verifying the self-signed certificate fails
+	_, err = http.Get("https://" + listener.Addr().String() + "/status")
+	assert.Error(t, err)
+
+	_, err = NewServer("0", env, WithTLS("missing.crt", "missing.key")).listen()
+	assert.ErrorContains(t, err, "could not load TLS certificate")
+}
+
+func TestServerUnixSocket(t *testing.T) {
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", &mockComponent{}))
+	assert.NoError(t, err)
+
+	// socket paths are limited in length, so a short directory is used
+	dir, err := os.MkdirTemp("", "envite")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "envite.sock")
+
+	s := NewServer("8081", env, WithUnixSocket(path, 0))
+	assert.Equal(t, "unix://"+path, s.URL())
+	done := make(chan error)
+	go func() {
+		done <- s.Start()
+	}()
+
+	client := NewUnixSocketClient(path)
+	var res *http.Response
+	assert.Eventually(t, func() bool {
+		res, err = client.Get("http://envite/status")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	err = NewServer("8081", env, WithUnixSocket(path, 0)).Start()
+	assert.ErrorContains(t, err, "socket is in use")
+
+	assert.NoError(t, s.Close())
+	assert.NoError(t, <-done)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, os.WriteFile(path, []byte("not a socket"), 0o600))
+	err = NewServer("8081", env, WithUnixSocket(path, 0)).Start()
+	assert.ErrorContains(t, err, "file exists and is not a socket")
 }
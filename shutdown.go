@@ -0,0 +1,113 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ExitPolicy represents what a Server does with its Environment once it is closed, see WithExitPolicy.
+type ExitPolicy string
+
+const (
+	// ExitPolicyLeave indicates that all components are left as they are, so they keep running after the Server exits.
+	ExitPolicyLeave ExitPolicy = "leave"
+
+	// ExitPolicyStop indicates that all components are stopped before the Server exits.
+	ExitPolicyStop ExitPolicy = "stop"
+
+	// ExitPolicyCleanup indicates that all components are stopped and cleaned up before the Server exits.
+	ExitPolicyCleanup ExitPolicy = "cleanup"
+)
+
+// ParseExitPolicy parses the provided string value into an ExitPolicy.
+// It returns the parsed ExitPolicy or an error if the value is not a valid exit policy.
+func ParseExitPolicy(value string) (ExitPolicy, error) {
+	switch ExitPolicy(value) {
+	case ExitPolicyLeave, "":
+		return ExitPolicyLeave, nil
+	case ExitPolicyStop:
+		return ExitPolicyStop, nil
+	case ExitPolicyCleanup:
+		return ExitPolicyCleanup, nil
+	}
+	return "", ErrInvalidExitPolicy{v: value}
+}
+
+// WithExitPolicy is a ServerOption that sets what Server.Close does with the Environment once the HTTP server
+// is shut down. Defaults to ExitPolicyLeave.
+func WithExitPolicy(policy ExitPolicy) ServerOption {
+	return func(s *Server) {
+		s.exitPolicy = policy
+	}
+}
+
+// exit applies the exit policy of the Server to its Environment.
+func (s *Server) exit(ctx context.Context) error {
+	switch s.exitPolicy {
+	case ExitPolicyLeave, "":
+		return nil
+	case ExitPolicyStop:
+		s.env.Logger(LogLevelInfo, "stopping all components before exiting")
+		return s.env.StopAll(ctx)
+	case ExitPolicyCleanup:
+		s.env.Logger(LogLevelInfo, "stopping and cleaning up all components before exiting")
+		err := s.env.StopAll(ctx)
+		if err != nil {
+			return err
+		}
+
+		return s.env.Cleanup(ctx)
+	}
+	return ErrInvalidExitPolicy{v: string(s.exitPolicy)}
+}
+
+// serve starts the Server, and closes it once a signal is received, applying its exit policy.
+// If another signal is received while closing, forceQuit is called, and serve returns without waiting for Close.
+// Progress of the shutdown is written to w.
+func (s *Server) serve(w io.Writer, signals <-chan os.Signal, forceQuit func()) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Start()
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case sig := <-signals:
+		_, _ = fmt.Fprintf(w, "received %s, shutting down ENVITE daemon (%s components on exit), "+
+			"send it again to force quit\n", sig, s.exitPolicy)
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- s.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			return err
+		}
+
+		return <-served
+	case sig := <-signals:
+		_, _ = fmt.Fprintf(w, "received %s while shutting down, forcing quit\n", sig)
+		forceQuit()
+		return fmt.Errorf("received %s while shutting down", sig)
+	}
+}
+
+// ErrInvalidExitPolicy is an error type representing an invalid exit policy.
+type ErrInvalidExitPolicy struct {
+	v string
+}
+
+func (e ErrInvalidExitPolicy) Error() string {
+	return fmt.Sprintf("invalid exit policy %s", e.v)
+}
@@ -0,0 +1,163 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseExitPolicy(t *testing.T) {
+	policy, err := ParseExitPolicy("leave")
+	assert.NoError(t, err)
+	assert.Equal(t, ExitPolicyLeave, policy)
+
+	policy, err = ParseExitPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, ExitPolicyLeave, policy)
+
+	policy, err = ParseExitPolicy("stop")
+	assert.NoError(t, err)
+	assert.Equal(t, ExitPolicyStop, policy)
+
+	policy, err = ParseExitPolicy("cleanup")
+	assert.NoError(t, err)
+	assert.Equal(t, ExitPolicyCleanup, policy)
+
+	_, err = ParseExitPolicy("invalid")
+	assert.ErrorContains(t, err, "invalid exit policy invalid")
+}
+
+// shutdownTestSocket returns the path of a Unix domain socket in a short temporary directory.
+func shutdownTestSocket(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "envite")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return filepath.Join(dir, "envite.sock")
+}
+
+func TestServerShutdown(t *testing.T) {
+	db := &mockComponent{}
+	service := &flakyComponent{mockComponent: &mockComponent{}}
+	service.hang.Store(true)
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().
+			AddComponent("db", db).
+			AddComponent("service", service, "db"),
+	)
+	assert.NoError(t, err)
+	_, err = env.StartComponent(context.Background(), "db")
+	assert.NoError(t, err)
+
+	path := shutdownTestSocket(t)
+	s := NewServer("8081", env, WithUnixSocket(path, 0), WithExitPolicy(ExitPolicyCleanup))
+	signals := make(chan os.Signal, 1)
+	var out bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- s.serve(&out, signals, func() {
+			t.Error("unexpected force quit")
+		})
+	}()
+
+	client := NewUnixSocketClient(path)
+	assert.Eventually(t, func() bool {
+		res, err := client.Get("http://envite/status")
+		if err != nil {
+			return false
+		}
+		_ = res.Body.Close()
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	// the start of service hangs until the context of the request is canceled by the shutdown
+	responded := make(chan int)
+	go func() {
+		res, err := client.Post("http://envite/start_component", applicationJSON,
+			strings.NewReader(`{"component_id": "service"}`))
+		if err != nil {
+			responded <- 0
+			return
+		}
+		_ = res.Body.Close()
+		responded <- res.StatusCode
+	}()
+	assert.Eventually(t, func() bool {
+		return service.attempts.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+	service.hang.Store(false)
+
+	signals <- os.Interrupt
+	assert.Equal(t, http.StatusInternalServerError, <-responded)
+	assert.NoError(t, <-done)
+	assert.Contains(t, out.String(), "received interrupt, shutting down")
+	assert.True(t, db.stopCalled)
+	assert.True(t, db.cleanupCalled)
+	assert.Equal(t, ComponentStatusStopped, db.status)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestServerShutdownLeave(t *testing.T) {
+	component := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+	err = env.StartAll(context.Background())
+	assert.NoError(t, err)
+
+	s := NewServer("8081", env, WithUnixSocket(shutdownTestSocket(t), 0))
+	signals := make(chan os.Signal, 1)
+	signals <- os.Interrupt
+	err = s.serve(&bytes.Buffer{}, signals, func() {
+		t.Error("unexpected force quit")
+	})
+	assert.NoError(t, err)
+	assert.False(t, component.stopCalled)
+	assert.Equal(t, ComponentStatusRunning, component.status)
+}
+
+func TestServerShutdownForceQuit(t *testing.T) {
+	release := make(chan struct{})
+	stopping := make(chan struct{})
+	component := &mockComponent{onStop: func() {
+		close(stopping)
+		<-release
+	}}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+	err = env.StartAll(context.Background())
+	assert.NoError(t, err)
+
+	s := NewServer("8081", env, WithUnixSocket(shutdownTestSocket(t), 0), WithExitPolicy(ExitPolicyStop))
+	signals := make(chan os.Signal, 1)
+	var out bytes.Buffer
+	var forced atomic.Bool
+	done := make(chan error)
+	go func() {
+		done <- s.serve(&out, signals, func() {
+			forced.Store(true)
+		})
+	}()
+
+	signals <- os.Interrupt
+	<-stopping
+	signals <- os.Interrupt
+	err = <-done
+	assert.ErrorContains(t, err, "received interrupt while shutting down")
+	assert.True(t, forced.Load())
+	assert.Contains(t, out.String(), "forcing quit")
+	close(release)
+}
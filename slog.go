@@ -0,0 +1,201 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// slogLevelTrace and slogLevelFatal are the slog levels LogLevelTrace and LogLevelFatal map to,
+// since slog has no such levels.
+const (
+	slogLevelTrace = slog.LevelDebug - 4
+	slogLevelFatal = slog.LevelError + 4
+)
+
+// slogLevel converts a LogLevel to the equivalent slog.Level.
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelTrace:
+		return slogLevelTrace
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	case LogLevelFatal:
+		return slogLevelFatal
+	}
+	return slog.LevelInfo
+}
+
+// logLevel converts a slog.Level to the closest LogLevel at or below it.
+func logLevel(level slog.Level) LogLevel {
+	switch {
+	case level >= slogLevelFatal:
+		return LogLevelFatal
+	case level >= slog.LevelError:
+		return LogLevelError
+	case level >= slog.LevelWarn:
+		return LogLevelWarn
+	case level >= slog.LevelInfo:
+		return LogLevelInfo
+	case level >= slog.LevelDebug:
+		return LogLevelDebug
+	}
+	return LogLevelTrace
+}
+
+// WithLogHandler is an Option function that sets a slog.Handler as the logger for the Environment.
+// It is an alternative to WithLogger, where log levels are mapped to slog levels, see LogLevel.
+func WithLogHandler(handler slog.Handler) Option {
+	return WithLogger(func(level LogLevel, message string) {
+		ctx := context.Background()
+		l := slogLevel(level)
+		if !handler.Enabled(ctx, l) {
+			return
+		}
+		_ = handler.Handle(ctx, slog.NewRecord(time.Now(), l, message, 0))
+	})
+}
+
+// writerHandler is a slog.Handler writing records to the output of a component via a Writer.
+type writerHandler struct {
+	writer *Writer
+	level  slog.Leveler
+	attrs  []slog.Attr
+	groups []string
+}
+
+// Enabled implements slog.Handler, enabling records at or above the minimum level of the handler, if any.
+func (h *writerHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.level == nil || level >= h.level.Level()
+}
+
+// Handle implements slog.Handler, writing record as an OutputRecord.
+func (h *writerHandler) Handle(_ context.Context, record slog.Record) error {
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		if len(attrs) == 0 {
+			break
+		}
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(attrs...)}}
+	}
+
+	h.writer.WriteRecord(OutputRecord{
+		Time:       record.Time,
+		Stream:     OutputStreamStdout,
+		Level:      logLevel(record.Level),
+		Text:       record.Message,
+		Attributes: attributes(append(append([]slog.Attr{}, h.attrs...), attrs...)),
+	})
+	return nil
+}
+
+// WithAttrs implements slog.Handler, returning a handler that adds attrs to every record.
+func (h *writerHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(attrs...)}}
+	}
+	result := *h
+	result.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &result
+}
+
+// WithGroup implements slog.Handler, returning a handler that nests the attributes of every record under name.
+func (h *writerHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	result := *h
+	result.groups = append(append([]string{}, h.groups...), name)
+	return &result
+}
+
+// attributes converts slog attributes to OutputRecord attributes, where groups become nested maps.
+// It returns nil if there are no attributes.
+func attributes(attrs []slog.Attr) map[string]any {
+	var result map[string]any
+	for _, attr := range attrs {
+		if attr.Equal(slog.Attr{}) {
+			continue
+		}
+		value := attr.Value.Resolve()
+		if value.Kind() != slog.KindGroup {
+			if result == nil {
+				result = make(map[string]any, len(attrs))
+			}
+			result[attr.Key] = attributeValue(value)
+			continue
+		}
+
+		group := attributes(value.Group())
+		if group == nil {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]any, len(attrs))
+		}
+		if attr.Key == "" {
+			// attributes of groups without a key are inlined, as slog handlers do
+			mergeAttributes(result, group)
+			continue
+		}
+		nested, ok := result[attr.Key].(map[string]any)
+		if !ok {
+			result[attr.Key] = group
+			continue
+		}
+		mergeAttributes(nested, group)
+	}
+	return result
+}
+
+// attributeValue converts a resolved slog value that is not a group to an OutputRecord attribute value.
+// Errors become their message, and values that cannot be marshaled as JSON become their string form,
+// so attributes keep their text in JSON records and log files.
+func attributeValue(value slog.Value) any {
+	switch value.Kind() {
+	case slog.KindFloat64:
+		if f := value.Float64(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return value.String()
+		}
+	case slog.KindAny:
+		v := value.Any()
+		if err, ok := v.(error); ok {
+			return err.Error()
+		}
+		if _, err := json.Marshal(v); err != nil {
+			return value.String()
+		}
+		return v
+	}
+	return value.Any()
+}
+
+// mergeAttributes adds the attributes of src to dst, merging nested groups present in both.
+func mergeAttributes(dst, src map[string]any) {
+	for key, value := range src {
+		srcGroup, srcIsGroup := value.(map[string]any)
+		dstGroup, dstIsGroup := dst[key].(map[string]any)
+		if srcIsGroup && dstIsGroup {
+			mergeAttributes(dstGroup, srcGroup)
+			continue
+		}
+		dst[key] = value
+	}
+}
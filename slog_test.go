@@ -0,0 +1,140 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWriterLevels(t *testing.T) {
+	component := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+
+	component.w.Debug("debug message")
+	component.w.Info("info message", "key", "value")
+	component.w.Warn("warn message", slog.Int("count", 3))
+	component.w.Error("error message", "error", "failed", slog.Group("request", "id", 7))
+	component.w.Log(LogLevelTrace, "trace message", "dangling")
+
+	reader := env.Output()
+	defer func() {
+		_ = reader.Close()
+	}()
+	expected := []struct {
+		level      LogLevel
+		text       string
+		attributes map[string]any
+	}{
+		{LogLevelDebug, "debug message", nil},
+		{LogLevelInfo, "info message", map[string]any{"key": "value"}},
+		{LogLevelWarn, "warn message", map[string]any{"count": int64(3)}},
+		{LogLevelError, "error message", map[string]any{"error": "failed", "request": map[string]any{"id": int64(7)}}},
+		{LogLevelTrace, "trace message", map[string]any{"!BADKEY": "dangling"}},
+	}
+	for _, e := range expected {
+		record := <-reader.Records()
+		assert.Equal(t, "component", record.ComponentID)
+		assert.Equal(t, e.level, record.Level)
+		assert.Equal(t, e.text, record.Text)
+		assert.Equal(t, e.attributes, record.Attributes)
+		assert.False(t, record.Time.IsZero())
+	}
+}
+
+func TestWriterErrorAttributes(t *testing.T) {
+	component := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+
+	component.w.Error("failed", "err", errors.New("connection refused"), "ratio", math.NaN(), "done", make(chan int))
+
+	reader := env.Output()
+	defer func() {
+		_ = reader.Close()
+	}()
+	record := <-reader.Records()
+	assert.Equal(t, "connection refused", record.Attributes["err"])
+	assert.Equal(t, "NaN", record.Attributes["ratio"])
+	assert.IsType(t, "", record.Attributes["done"])
+
+	data, err := json.Marshal(record)
+	assert.NoError(t, err)
+	var decoded OutputRecord
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "connection refused", decoded.Attributes["err"])
+}
+
+func TestWriterLogger(t *testing.T) {
+	component := &mockComponent{}
+	env, err := NewEnvironment("test-env", NewComponentGraph().AddComponent("component", component))
+	assert.NoError(t, err)
+
+	logger := component.w.Logger(slog.LevelInfo).With("service", "api").WithGroup("http")
+	logger.Debug("discarded")
+	logger.Info("request", "method", "GET", slog.Group("response", "status", 200))
+	logger.With("path", "/").Error("failed")
+	logger.Log(context.Background(), slog.LevelError+4, "fatal")
+	component.w.Logger(nil).Log(context.Background(), slog.LevelDebug-4, "trace")
+
+	reader := env.Output()
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	record := <-reader.Records()
+	assert.Equal(t, LogLevelInfo, record.Level)
+	assert.Equal(t, "request", record.Text)
+	assert.Equal(t, map[string]any{
+		"service": "api",
+		"http": map[string]any{
+			"method":   "GET",
+			"response": map[string]any{"status": int64(200)},
+		},
+	}, record.Attributes)
+
+	record = <-reader.Records()
+	assert.Equal(t, LogLevelError, record.Level)
+	assert.Equal(t, map[string]any{"service": "api", "http": map[string]any{"path": "/"}}, record.Attributes)
+
+	record = <-reader.Records()
+	assert.Equal(t, LogLevelFatal, record.Level)
+	assert.Equal(t, map[string]any{"service": "api"}, record.Attributes)
+
+	record = <-reader.Records()
+	assert.Equal(t, LogLevelTrace, record.Level)
+	assert.Equal(t, "trace", record.Text)
+	assert.Nil(t, record.Attributes)
+}
+
+func TestWithLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})
+	env, err := NewEnvironment(
+		"test-env",
+		NewComponentGraph().AddComponent("component", &mockComponent{}),
+		WithLogHandler(handler),
+	)
+	assert.NoError(t, err)
+
+	env.Logger(LogLevelInfo, "info message")
+	env.Logger(LogLevelWarn, "warn message")
+	env.Logger(LogLevelFatal, "fatal message")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "level=WARN")
+	assert.Contains(t, lines[0], `msg="warn message"`)
+	assert.Contains(t, lines[1], "level=ERROR+4")
+	assert.Contains(t, lines[1], `msg="fatal message"`)
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 HUMAN Security.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package envite
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OutputStreaming configures how Execute writes the output of components while it starts, stops or restarts
+// the environment, see WithOutputStreaming.
+// Each line is prefixed with the ID of the component that wrote it, colored differently for each component.
+//
+// Fields:
+// - Writer: The writer to write output to, such as os.Stdout.
+// - Rendering: How colors are rendered. Defaults to OutputRenderingPlain.
+// - Components: The IDs of the components whose output is written. Empty means all components.
+// - MinLevel: The minimum level of lines of output that are written.
+// - Quiet: Whether to write no output while operations run, only the output of failed components.
+// - TailOnFailure: The number of last lines of each failed component to write when an operation fails, unfiltered.
+type OutputStreaming struct {
+	Writer        io.Writer
+	Rendering     OutputRendering
+	Components    []string
+	MinLevel      LogLevel
+	Quiet         bool
+	TailOnFailure int
+}
+
+// streamingColors are the colors of component IDs prefixing lines of streamed output, assigned in turn.
+var streamingColors = []string{"cyan", "magenta", "green", "blue", "yellow", "bright_cyan", "bright_magenta",
+	"bright_green", "bright_blue", "bright_yellow"}
+
+// outputStream writes lines of output prefixed with the ID of their component.
+type outputStream struct {
+	config OutputStreaming
+	width  int
+	colors map[string]string
+}
+
+// newOutputStream creates a new outputStream for the components of env.
+func newOutputStream(env *Environment, config OutputStreaming) *outputStream {
+	if config.Rendering == "" {
+		config.Rendering = OutputRenderingPlain
+	}
+	s := &outputStream{config: config, colors: make(map[string]string, len(env.graph.ids))}
+	for i, id := range env.graph.ids {
+		s.colors[id] = streamingColors[i%len(streamingColors)]
+		if len(id) > s.width {
+			s.width = len(id)
+		}
+	}
+	return s
+}
+
+// run runs operation while writing the output of components, and then writes the last lines of output
+// of each component that failed, if any.
+func (s *outputStream) run(env *Environment, operation func() error) error {
+	var reader *Reader
+	done := make(chan struct{})
+	if s.config.Quiet {
+		close(done)
+	} else {
+		options := []ReaderOption{AtLevel(s.config.MinLevel)}
+		if len(s.config.Components) > 0 {
+			options = append(options, ForComponents(s.config.Components...))
+		}
+		reader = env.Output(options...)
+		go func() {
+			defer close(done)
+			for record := range reader.Records() {
+				s.write(record)
+			}
+		}()
+	}
+
+	// failed components are collected from lifecycle events, since only some operations return an ApplyError
+	failed := make(map[string]struct{})
+	var subscription *Subscription
+	collected := make(chan struct{})
+	if s.config.TailOnFailure > 0 {
+		subscription = env.Subscribe()
+		go func() {
+			defer close(collected)
+			for event := range subscription.Chan() {
+				if event.Type == EventComponentFailed {
+					failed[event.ComponentID] = struct{}{}
+				}
+			}
+		}()
+	} else {
+		close(collected)
+	}
+
+	err := operation()
+	if reader != nil {
+		reader.finish()
+		<-done
+		_ = reader.Close()
+	}
+	if subscription != nil {
+		_ = subscription.Close()
+	}
+	<-collected
+
+	if err != nil {
+		for _, id := range env.graph.ids {
+			if _, ok := failed[id]; ok {
+				s.tail(env, id)
+			}
+		}
+	}
+	return err
+}
+
+// tail writes the last lines of output of a component kept in memory, following a header line.
+func (s *outputStream) tail(env *Environment, componentID string) {
+	matches := env.SearchOutput(0, ForComponents(componentID), FromLastLines(s.config.TailOnFailure))
+	header := fmt.Sprintf("last %d lines of output of %s:", len(matches), componentID)
+	header = renderText(header, []OutputSpan{{Start: 0, End: len(header), Bold: true}}, s.config.Rendering)
+	_, _ = fmt.Fprintln(s.config.Writer, header)
+	for _, match := range matches {
+		s.write(match.Record)
+	}
+}
+
+// write writes a line of output prefixed with the ID of its component.
+func (s *outputStream) write(record OutputRecord) {
+	id := record.ComponentID
+	padding := s.width - len(id)
+	if padding < 0 {
+		padding = 0
+	}
+	prefix := id + strings.Repeat(" ", padding) + " | "
+	spans := []OutputSpan{{Start: 0, End: len(id), Color: s.colors[id], Bold: true}}
+	for _, span := range record.highlightedSpans() {
+		span.Start += len(prefix)
+		span.End += len(prefix)
+		spans = append(spans, span)
+	}
+	_, _ = fmt.Fprintln(s.config.Writer, renderText(prefix+record.Text, spans, s.config.Rendering))
+}
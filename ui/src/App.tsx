@@ -2,11 +2,11 @@
 // Use of this source code is governed by a MIT style
 // license that can be found in the LICENSE file.
 
-import React, { useCallback, useEffect, useState } from 'react';
+import React, { useCallback, useEffect, useRef, useState } from 'react';
 import './App.css';
 import * as api from './api';
 import ComponentsBar from './ComponentsBar';
-import { ApiCall, Message, Status } from './api';
+import { ApiCall, Component, Message, Status } from './api';
 import {
     Alert,
     AlertTitle,
@@ -30,7 +30,7 @@ import FilterListIcon from '@mui/icons-material/FilterList';
 import FavoriteIcon from '@mui/icons-material/Favorite';
 import ProductTour, { ProductTourProps } from './ProductTour';
 
-const REFRESH_STATUS_INTERVAL = 5000;
+const REFRESH_OUTPUT_INTERVAL = 5000;
 
 const theme = createTheme({
     palette: {
@@ -225,6 +225,10 @@ function App() {
         [fetchStatus, reportApiError, reportApiSuccess]
     );
 
+    // the events and output subscriptions outlive fetchStatus, which changes with every status update
+    const fetchStatusRef = useRef(fetchStatus);
+    fetchStatusRef.current = fetchStatus;
+
     const getOutputContinuously = useCallback(async () => {
         try {
             await api.getOutput((component, messages) => {
@@ -234,25 +238,20 @@ function App() {
                     newOutput[component].push(...messages);
                     return newOutput;
                 });
+                // alerts are counted in the status of the component, without a lifecycle event
+                if (messages.some((m) => m.alert)) {
+                    fetchStatusRef.current().then();
+                }
             });
         } catch (e) {
-            setTimeout(getOutputContinuously, REFRESH_STATUS_INTERVAL);
+            setTimeout(getOutputContinuously, REFRESH_OUTPUT_INTERVAL);
         }
     }, []);
 
-    const getStatusContinuously = useCallback(() => {
-        const interval = setInterval(() => {
-            fetchStatus().then();
-        }, REFRESH_STATUS_INTERVAL);
-        return () => {
-            clearInterval(interval);
-        };
-    }, [fetchStatus]);
-
-    useEffect(() => {
-        fetchStatus().then();
-        return getStatusContinuously();
-    }, [fetchStatus, getStatusContinuously]);
+    useEffect(
+        () => api.subscribeEvents(() => fetchStatusRef.current().then()),
+        []
+    );
 
     useEffect(() => {
         getOutputContinuously().then();
@@ -400,8 +399,8 @@ function formatHttpError(e: any): string {
 }
 
 function isDifferentStatus(newStatus: Status, oldStatus: Status): boolean {
-    const newStatuses = newStatus.components.flat().map((c) => c.status);
-    const oldStatuses = oldStatus.components.flat().map((c) => c.status);
+    const newStatuses = newStatus.components.flat().map(componentState);
+    const oldStatuses = oldStatus.components.flat().map(componentState);
     if (
         (newStatuses && !oldStatuses) ||
         (oldStatuses && !newStatuses) ||
@@ -423,6 +422,10 @@ function isDifferentStatus(newStatus: Status, oldStatus: Status): boolean {
     return false;
 }
 
+function componentState(c: Component): string {
+    return `${c.status}:${c.alerts?.severity || ''}:${c.alerts?.count || 0}`;
+}
+
 function countRunningComponents(status: Status | null) {
     if (!status || !status.components) {
         return 0;
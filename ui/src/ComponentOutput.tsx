@@ -5,7 +5,6 @@
 import React, { useEffect, useRef, useState } from 'react';
 import './ComponentOutput.css';
 import { Message, Component } from './api';
-import { ansicolor } from 'ansicolor';
 import AlarmIcon from '@mui/icons-material/Alarm';
 import AlarmOffIcon from '@mui/icons-material/AlarmOff';
 import OpenInFullIcon from '@mui/icons-material/OpenInFull';
@@ -147,16 +146,13 @@ function ComponentOutput(props: ComponentOutputProps) {
     );
 }
 
-const formattingStyles: { [key: string]: string } = {
-    italic: 'font-style: italic',
-    bold: 'font-weight: bold',
-    red: 'color: rgb(190 86 86)',
-    green: 'color: #00ffbb',
-    yellow: 'color: rgb(223 138 88)',
-    blue: 'color: rgb(124 124 221)',
-    magenta: 'color: rgb(205 108 205)',
-    cyan: 'color: rgb(82 177 255)',
-    dim: 'color: rgb(90 90 90)'
+const outputStyles: { [key: string]: string } = {
+    dim: 'color: rgb(90 90 90)',
+    stderr: 'color: rgb(190 86 86)',
+    warning:
+        'color: rgb(223 138 88); background: rgb(223 138 88 / 15%); font-weight: bold',
+    error:
+        'color: rgb(190 86 86); background: rgb(190 86 86 / 15%); font-weight: bold'
 };
 
 function formatOutput(data: Message[] | undefined, showTime: boolean) {
@@ -167,32 +163,17 @@ function formatOutput(data: Message[] | undefined, showTime: boolean) {
         .map((msg) => {
             let time = '';
             if (showTime) {
-                time = `<span style="${formattingStyles.dim}">${msg.time}</span> `;
+                time = `<span style="${outputStyles.dim}">${msg.time}</span> `;
             }
-            return (
-                time +
-                ansicolor
-                    .parse(msg.data)
-                    .spans.map((span) => {
-                        let styles = [];
-                        if (
-                            span.color?.name &&
-                            formattingStyles[span.color?.name]
-                        ) {
-                            styles.push(formattingStyles[span.color?.name]);
-                        }
-                        if (span.italic) {
-                            styles.push(formattingStyles.italic);
-                        }
-                        if (span.bold) {
-                            styles.push(formattingStyles.bold);
-                        }
-                        return `<span style="${styles.join(';')}">${
-                            span.text
-                        }</span>`;
-                    })
-                    .join('')
-            );
+            const style = msg.alert
+                ? outputStyles[msg.alert]
+                : msg.stream === 'stderr'
+                ? outputStyles.stderr
+                : '';
+            if (style) {
+                return `${time}<span style="${style}">${msg.data}</span>\n`;
+            }
+            return `${time}${msg.data}\n`;
         })
         .join('');
 }
@@ -4,7 +4,7 @@
 
 import React from 'react';
 import './ComponentTitle.css';
-import { Component } from './api';
+import { Alerts, Component } from './api';
 import { NavLink } from 'react-router-dom';
 
 interface ComponentTitleProps {
@@ -33,9 +33,21 @@ function ComponentTitle(props: ComponentTitleProps) {
                 >
                     {props.component.status}
                 </span>
+                {props.component.alerts && (
+                    <span
+                        className={`alerts ${props.component.alerts.severity}`}
+                        title={`Highest severity: ${props.component.alerts.severity}`}
+                    >
+                        {formatAlerts(props.component.alerts)}
+                    </span>
+                )}
             </div>
         </div>
     );
 }
 
+function formatAlerts(alerts: Alerts) {
+    return `${alerts.count} ${alerts.count === 1 ? 'alert' : 'alerts'}`;
+}
+
 export default ComponentTitle;
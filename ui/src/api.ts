@@ -16,11 +16,31 @@ export interface Component {
     type: string;
     status: 'stopped' | 'failed' | 'starting' | 'running' | 'finished';
     config: any;
+    alerts?: Alerts;
+}
+
+export type AlertSeverity = 'warning' | 'error';
+
+// Alerts describes the alerts raised by the output of a component since it was last started.
+export interface Alerts {
+    severity: AlertSeverity;
+    count: number;
 }
 
 export interface Message {
     time: string;
+    // data is the text of the line, rendered as HTML
     data: string;
+    stream?: string;
+    alert?: AlertSeverity;
+}
+
+interface OutputRecord {
+    component_id: string;
+    time: string;
+    stream?: string;
+    text: string;
+    alert?: AlertSeverity;
 }
 
 export interface ApiCall<T> {
@@ -145,34 +165,38 @@ export function stopAll(title: string, cleanup: boolean): ApiCall<void> {
 export async function getOutput(
     onData: (component: string, messages: Message[]) => void
 ) {
-    const response = await fetch(BASE_URL + '/output');
+    const response = await fetch(
+        BASE_URL + '/output?format=ndjson&color=html'
+    );
     const reader = response.body!.getReader();
-    let component = '';
-    let time = '';
+    const decoder = new TextDecoder('utf-8');
+    let pending = '';
     while (true) {
         const { value, done } = await reader.read();
         if (done) {
             return;
         }
-        const string = new TextDecoder('utf-8').decode(value);
-        const lines = string.replace(/\r\n/g, '\n').split('\n');
+        // a chunk may end in the middle of a record, which is completed by the next chunk
+        const lines = (pending + decoder.decode(value, { stream: true })).split(
+            '\n'
+        );
+        pending = lines.pop() || '';
         const byComponent: { [key: string]: Message[] } = {};
         for (const line of lines) {
             if (!line.trim()) {
                 continue;
             }
-            let data = line;
-            if (line.startsWith('<component>')) {
-                const timePos = line.indexOf('<time>');
-                const msgPos = line.indexOf('<msg>');
-                component = line.substring(11, timePos);
-                time = line.substring(timePos + 6, msgPos).padEnd(28, '0');
-                data = line.substring(msgPos + 5) + '\n';
-            }
+            const record: OutputRecord = JSON.parse(line);
+            const component = record.component_id;
             if (!byComponent[component]) {
                 byComponent[component] = [];
             }
-            byComponent[component].push({ time, data });
+            byComponent[component].push({
+                time: record.time,
+                data: record.text,
+                stream: record.stream,
+                alert: record.alert
+            });
         }
         for (const component of Object.keys(byComponent)) {
             onData(component, byComponent[component]);
@@ -180,6 +204,48 @@ export async function getOutput(
     }
 }
 
+const STATUS_EVENTS = [
+    'component_prepared',
+    'component_starting',
+    'component_running',
+    'component_restarting',
+    'component_failed',
+    'component_stopped',
+    'component_cleaned',
+    'operation_finished'
+];
+
+const EVENTS_RECONNECT_INTERVAL = 5000;
+
+// subscribeEvents follows the /events stream and calls onChange whenever a lifecycle event may have changed the
+// environment status, including when the stream connects or drops. It returns a function that closes the stream.
+export function subscribeEvents(onChange: () => void): () => void {
+    let source: EventSource | null = null;
+    let timeout: ReturnType<typeof setTimeout> | null = null;
+    let closed = false;
+    const connect = () => {
+        source = new EventSource(BASE_URL + '/events');
+        source.onopen = () => onChange();
+        source.onerror = () => {
+            onChange();
+            if (!closed && source?.readyState === EventSource.CLOSED) {
+                timeout = setTimeout(connect, EVENTS_RECONNECT_INTERVAL);
+            }
+        };
+        for (const type of STATUS_EVENTS) {
+            source.addEventListener(type, () => onChange());
+        }
+    };
+    connect();
+    return () => {
+        closed = true;
+        if (timeout) {
+            clearTimeout(timeout);
+        }
+        source?.close();
+    };
+}
+
 interface Canceler {
     canceled: boolean;
     cancelSource: CancelTokenSource;